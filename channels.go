@@ -0,0 +1,158 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// Channels rewrites the channel operations to calls of the functions
+// that implement them:
+//
+//	ch := make(chan int, 3)  ->  ch := _go_chan_make(int, 3)
+//	ch <- 1                  ->  _go_chan_send(ch, 1)
+//	x := <-ch                ->  x := _go_chan_recv(ch)
+//	v, ok := <-ch            ->  v, ok := _go_chan_recv_ok(ch)
+//	close(ch)                ->  _go_chan_close(ch)
+//
+// A select statement is a switch on the case that is chosen:
+//
+//	select {                 ->  switch _go_chan_select(true, _go_chan_recv_ready(ch)) {
+//	case v := <-ch:          ->  case 0:
+//	                         ->      v := _go_chan_recv(ch)
+//	    fmt.Println(v)       ->      fmt.Println(v)
+//	default:                 ->  default:
+//	}                        ->  }
+//
+// There are no goroutines, so an operation that would block forever ends
+// the program with a deadlock error, like it does in Go.
+func Channels(source string) string {
+	fset, file := parseSource(source)
+
+	changed := false
+	call := func(name string, pos token.Pos, args ...ast.Expr) *ast.CallExpr {
+		changed = true
+		return &ast.CallExpr{Fun: &ast.Ident{NamePos: pos, Name: name}, Lparen: pos, Args: args, Rparen: pos}
+	}
+	// A receive with two values gives if the channel is open
+	commaOk := func(rhs []ast.Expr, lhs int) {
+		if lhs != 2 || len(rhs) != 1 {
+			return
+		}
+		if u, ok := rhs[0].(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+			rhs[0] = call("_go_chan_recv_ok", u.Pos(), u.X)
+		}
+	}
+	sends := func(list []ast.Stmt) {
+		for i, stmt := range list {
+			if s, ok := stmt.(*ast.SendStmt); ok {
+				list[i] = &ast.ExprStmt{X: call("_go_chan_send", s.Pos(), s.Chan, s.Value)}
+			}
+		}
+	}
+	selects := func(list []ast.Stmt) {
+		for i, stmt := range list {
+			switch s := stmt.(type) {
+			case *ast.SelectStmt:
+				list[i] = selectSwitch(s)
+				changed = true
+			case *ast.LabeledStmt:
+				if sel, ok := s.Stmt.(*ast.SelectStmt); ok {
+					s.Stmt = selectSwitch(sel)
+					changed = true
+				}
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			selects(n.List)
+		case *ast.CaseClause:
+			selects(n.Body)
+		case *ast.CommClause:
+			selects(n.Body)
+		}
+		return true
+	})
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			commaOk(n.Rhs, len(n.Lhs))
+		case *ast.ValueSpec:
+			commaOk(n.Values, len(n.Names))
+		case *ast.BlockStmt:
+			sends(n.List)
+		case *ast.CaseClause:
+			sends(n.Body)
+		case *ast.CommClause:
+			sends(n.Body)
+		}
+		return true
+	})
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		switch e := e.(type) {
+		case *ast.UnaryExpr:
+			if e.Op == token.ARROW {
+				return call("_go_chan_recv", e.Pos(), e.X)
+			}
+		case *ast.CallExpr:
+			id, ok := e.Fun.(*ast.Ident)
+			if !ok || len(e.Args) == 0 {
+				break
+			}
+			switch {
+			case id.Name == "close" && len(e.Args) == 1:
+				return call("_go_chan_close", e.Pos(), e.Args...)
+			case id.Name == "make":
+				if ch, ok := e.Args[0].(*ast.ChanType); ok {
+					return call("_go_chan_make", e.Pos(), append([]ast.Expr{ch.Value}, e.Args[1:]...)...)
+				}
+			}
+		}
+		return e
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// selectSwitch returns a switch that replaces the given select statement. The switch is
+// on the index of the case that _go_chan_select chooses, among the cases that can proceed,
+// or -1 for the default case. The operation of the chosen case is then done in the case.
+func selectSwitch(sel *ast.SelectStmt) *ast.SwitchStmt {
+	pos := sel.Pos()
+	hasDefault := "false"
+	var ready []ast.Expr
+	var clauses, defaults []ast.Stmt
+	for _, stmt := range sel.Body.List {
+		clause := stmt.(*ast.CommClause)
+		if clause.Comm == nil {
+			hasDefault = "true"
+			defaults = append(defaults, &ast.CaseClause{Case: clause.Pos(), Colon: clause.Colon, Body: clause.Body})
+			continue
+		}
+		var ch ast.Expr
+		readyFunction := "_go_chan_recv_ready"
+		switch comm := clause.Comm.(type) {
+		case *ast.SendStmt:
+			ch = comm.Chan
+			readyFunction = "_go_chan_send_ready"
+		case *ast.ExprStmt:
+			ch = comm.X.(*ast.UnaryExpr).X
+		case *ast.AssignStmt:
+			ch = comm.Rhs[0].(*ast.UnaryExpr).X
+		}
+		index := &ast.BasicLit{ValuePos: clause.Pos(), Kind: token.INT, Value: strconv.Itoa(len(ready))}
+		ready = append(ready, parseGenerated(readyFunction+"("+exprString(ch)+")", pos))
+		body := append([]ast.Stmt{clause.Comm}, clause.Body...)
+		clauses = append(clauses, &ast.CaseClause{Case: clause.Pos(), List: []ast.Expr{index}, Colon: clause.Colon, Body: body})
+	}
+	// The default case is the last case, since the switch is translated to an if/else chain
+	clauses = append(clauses, defaults...)
+	args := append([]ast.Expr{&ast.Ident{NamePos: pos, Name: hasDefault}}, ready...)
+	tag := &ast.CallExpr{Fun: &ast.Ident{NamePos: pos, Name: "_go_chan_select"}, Lparen: pos, Args: args, Rparen: pos}
+	return &ast.SwitchStmt{Switch: pos, Tag: tag, Body: &ast.BlockStmt{Lbrace: sel.Body.Lbrace, List: clauses, Rbrace: sel.Body.Rbrace}}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+)
+
+var (
+	exprType     = reflect.TypeOf((*ast.Expr)(nil)).Elem()
+	nodeType     = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	callExprType = reflect.TypeOf((*ast.CallExpr)(nil))
+)
+
+// RuntimeChecks rewrites the operations in the given Go source code that may
+// fail at run time to calls to functions in the generated C++ code. These
+// functions panic with the same runtime errors as Go does:
+//
+//	a / b   ->  _go_div(a, b)
+//	a % b   ->  _go_mod(a, b)
+//	x[i]    ->  _go_index(x, i)
+//	x[a:b]  ->  _go_slicing(x, a, b)
+//	*p      ->  _go_deref(p)
+//	x.(T)   ->  _go_assert(x, T)
+//
+// Assignments to x[i] are left as they are, since IndexReference handles them.
+func RuntimeChecks(source string) string {
+	fset, file := parseSource(source)
+	rewriteChildren(file)
+	return formatFile(fset, file)
+}
+
+// call creates a call expression at the position of the given expression
+func call(name string, at ast.Expr, args ...ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{Fun: &ast.Ident{NamePos: at.Pos(), Name: name}, Lparen: at.Pos(), Args: args, Rparen: at.End()}
+}
+
+// copyExpr returns a copy of the given expression, for expressions that must be evaluated twice
+func copyExpr(e ast.Expr) ast.Expr {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), e); err != nil {
+		return e
+	}
+	if c, err := parser.ParseExpr(buf.String()); err == nil {
+		return c
+	}
+	return e
+}
+
+// rewriteChildren rewrites the expressions that are found within the given node
+func rewriteChildren(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+		// Types can not fail at run time
+		return
+	case *ast.GenDecl:
+		if n.Tok == token.CONST {
+			// Constant expressions are evaluated when compiling
+			return
+		}
+	case *ast.AssignStmt:
+		rewriteAssignment(n)
+		return
+	case *ast.IncDecStmt:
+		n.X = rewriteTarget(n.X)
+		return
+	case *ast.CallExpr:
+		if id, ok := n.Fun.(*ast.Ident); ok {
+			if typeArg, ok := typeArguments[id.Name]; ok {
+				// Functions like _go_box(T, value), where T is a type
+				for i, arg := range n.Args {
+					if i != typeArg {
+						n.Args[i] = rewriteExpr(arg)
+					}
+				}
+				return
+			}
+		}
+		if star, ok := ast.Unparen(n.Fun).(*ast.StarExpr); ok {
+			// A conversion to a pointer type, like (*T)(x)
+			rewriteChildren(star)
+			for i, arg := range n.Args {
+				n.Args[i] = rewriteExpr(arg)
+			}
+			return
+		}
+	}
+	v := reflect.ValueOf(node).Elem()
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if v.Type().Field(i).Name == "Type" {
+			// Fields, specs and composite literals have types that should not be rewritten
+			continue
+		}
+		switch {
+		case f.Type() == exprType:
+			if !f.IsNil() {
+				f.Set(reflect.ValueOf(rewriteExpr(f.Interface().(ast.Expr))))
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem() == exprType:
+			for j := 0; j < f.Len(); j++ {
+				f.Index(j).Set(reflect.ValueOf(rewriteExpr(f.Index(j).Interface().(ast.Expr))))
+			}
+		case f.Type().Implements(nodeType) && (f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface):
+			if !f.IsNil() {
+				rewriteChildren(f.Interface().(ast.Node))
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Implements(nodeType):
+			for j := 0; j < f.Len(); j++ {
+				if !f.Index(j).IsNil() {
+					rewriteChildren(f.Index(j).Interface().(ast.Node))
+				}
+			}
+		}
+	}
+}
+
+// rewriteTarget rewrites an expression that is assigned to. An index
+// expression is kept, but the expressions within it are rewritten.
+func rewriteTarget(e ast.Expr) ast.Expr {
+	if index, ok := e.(*ast.IndexExpr); ok {
+		index.X = rewriteExpr(index.X)
+		index.Index = rewriteExpr(index.Index)
+		return index
+	}
+	return rewriteExpr(e)
+}
+
+// rewriteAssignment rewrites the expressions in an assignment,
+// including "v, ok := m[k]", "v, ok := x.(T)" and "a /= b"
+func rewriteAssignment(n *ast.AssignStmt) {
+	if len(n.Lhs) == 2 && len(n.Rhs) == 1 {
+		switch rhs := n.Rhs[0].(type) {
+		case *ast.IndexExpr:
+			// v, ok := m[k]
+			n.Rhs[0] = rewriteTarget(rhs)
+			return
+		case *ast.TypeAssertExpr:
+			// v, ok := x.(T)
+			n.Rhs[0] = call("_go_assert_ok", rhs, rewriteExpr(rhs.X), rhs.Type)
+			return
+		}
+	}
+	if (n.Tok == token.QUO_ASSIGN || n.Tok == token.REM_ASSIGN) && len(n.Lhs) == 1 {
+		// a /= b -> a = _go_div(a, b)
+		name := "_go_div"
+		if n.Tok == token.REM_ASSIGN {
+			name = "_go_mod"
+		}
+		n.Rhs[0] = call(name, n.Rhs[0], rewriteExpr(copyExpr(n.Lhs[0])), rewriteExpr(n.Rhs[0]))
+		n.Tok = token.ASSIGN
+		n.Lhs[0] = rewriteTarget(n.Lhs[0])
+		return
+	}
+	for i, lhs := range n.Lhs {
+		n.Lhs[i] = rewriteTarget(lhs)
+	}
+	for i, rhs := range n.Rhs {
+		n.Rhs[i] = rewriteExpr(rhs)
+	}
+}
+
+// rewriteExpr rewrites the given expression, after rewriting the expressions within it
+func rewriteExpr(e ast.Expr) ast.Expr {
+	rewriteChildren(e)
+	switch x := e.(type) {
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case token.QUO:
+			return call("_go_div", x, x.X, x.Y)
+		case token.REM:
+			return call("_go_mod", x, x.X, x.Y)
+		}
+	case *ast.IndexExpr:
+		return call("_go_index", x, x.X, x.Index)
+	case *ast.SliceExpr:
+		low, high := x.Low, x.High
+		if low == nil {
+			low = &ast.BasicLit{Kind: token.INT, Value: "0"}
+		}
+		if high == nil {
+			high = ast.NewIdent("_go_end")
+		}
+		if x.Slice3 {
+			return call("_go_slicing", x, x.X, low, high, x.Max)
+		}
+		return call("_go_slicing", x, x.X, low, high)
+	case *ast.StarExpr:
+		return call("_go_deref", x, x.X)
+	case *ast.TypeAssertExpr:
+		if x.Type != nil {
+			return call("_go_assert", x, x.X, x.Type)
+		}
+	}
+	return e
+}
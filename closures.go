@@ -0,0 +1,312 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+const unboxedPrefix = "_go_unboxed_"
+
+// Closures rewrites the local variables that are captured by function
+// literals, or that have their address taken and escape, to boxes, that are shared by the
+// function, the function literals and the pointers, so that the variables live
+// for as long as they are used:
+//
+//	x := 0                    ->  x := _go_box(int, 0)
+//	var s []string            ->  s := _go_box([]string)
+//	x                         ->  (*x)
+//	&x                        ->  x
+//	for i := 0; i < n; i++ {  ->  for i := _go_box(int, 0); (*i) < n; _go_rebox(i)++ {
+//
+// Loop variables get a new box for every iteration, like in Go 1.22.
+// Parameters and range variables are renamed, and boxed at the start of the body.
+func Closures(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	boxed := capturedVariables(file, info)
+	escapes := analyzeEscapes(file, info, pkg)
+	for v := range escapes.addressed {
+		// The variables that have their address taken are only boxed if they escape
+		if escapes.escapes(escapeNode{variable: v}) != "" {
+			boxed[v] = true
+		}
+	}
+	if len(boxed) == 0 {
+		return source
+	}
+	isBoxed := func(id *ast.Ident) bool {
+		if obj := info.Defs[id]; obj != nil {
+			return boxed[obj]
+		}
+		return false
+	}
+
+	// Use the boxes
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		switch x := e.(type) {
+		case *ast.Ident:
+			if boxed[info.Uses[x]] {
+				return &ast.ParenExpr{Lparen: x.Pos(), X: &ast.StarExpr{Star: x.Pos(), X: x}, Rparen: x.End()}
+			}
+		case *ast.UnaryExpr:
+			// The address of a boxed variable is the box
+			if paren, ok := x.X.(*ast.ParenExpr); ok && x.Op == token.AND {
+				if star, ok := paren.X.(*ast.StarExpr); ok {
+					if id, ok := star.X.(*ast.Ident); ok && boxed[info.Uses[id]] {
+						return id
+					}
+				}
+			}
+		}
+		return e
+	})
+
+	// Declare the boxes
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			boxParameters(n.Type, n.Body, isBoxed, info)
+		case *ast.FuncLit:
+			boxParameters(n.Type, n.Body, isBoxed, info)
+		case *ast.BlockStmt:
+			n.List = boxStatements(n.List, isBoxed, info)
+		case *ast.CaseClause:
+			n.Body = boxStatements(n.Body, isBoxed, info)
+		case *ast.CommClause:
+			n.Body = boxStatements(n.Body, isBoxed, info)
+		case *ast.IfStmt:
+			boxStatements([]ast.Stmt{n.Init}, isBoxed, info)
+		case *ast.SwitchStmt:
+			boxStatements([]ast.Stmt{n.Init}, isBoxed, info)
+		case *ast.ForStmt:
+			boxStatements([]ast.Stmt{n.Init}, isBoxed, info)
+			reboxLoopVariable(n.Post)
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, e := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := e.(*ast.Ident); ok && isBoxed(id) {
+						n.Body.List = append([]ast.Stmt{unboxedCopy(id, info)}, n.Body.List...)
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	return formatFile(fset, file)
+}
+
+// capturedVariables finds the local variables that are used by function literals,
+// but declared outside of them
+func capturedVariables(file *ast.File, info *types.Info) map[types.Object]bool {
+	captured := map[types.Object]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			v, ok := info.Uses[id].(*types.Var)
+			if !ok || !isLocal(v) {
+				return true
+			}
+			if v.Pos() < lit.Pos() || v.Pos() >= lit.End() {
+				captured[v] = true
+			}
+			return true
+		})
+		return true
+	})
+	return captured
+}
+
+// isLocal checks if the given variable is a local variable or a parameter, with a valid type
+func isLocal(v *types.Var) bool {
+	return !v.IsField() && v.Pkg() != nil && v.Parent() != v.Pkg().Scope() && v.Type() != types.Typ[types.Invalid]
+}
+
+// goTypeString returns a Go type as it is written in the main package
+func goTypeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p.Name() == "main" {
+			return ""
+		}
+		return p.Name()
+	})
+}
+
+// box creates the expression _go_box(T) or _go_box(T, value), for the variable with the given name
+func box(id *ast.Ident, value ast.Expr, info *types.Info) ast.Expr {
+	args := []ast.Expr{ast.NewIdent(goTypeString(info.Defs[id].Type()))}
+	if value != nil {
+		args = append(args, value)
+	}
+	return &ast.CallExpr{Fun: &ast.Ident{NamePos: id.Pos(), Name: "_go_box"}, Lparen: id.Pos(), Args: args, Rparen: id.End()}
+}
+
+// unboxedCopy renames a variable that is declared elsewhere, like a parameter,
+// and returns a statement that declares a box with the same name and value
+func unboxedCopy(id *ast.Ident, info *types.Info) ast.Stmt {
+	name := id.Name
+	value := box(id, ast.NewIdent(unboxedPrefix+name), info)
+	id.Name = unboxedPrefix + name
+	return &ast.AssignStmt{Lhs: []ast.Expr{ast.NewIdent(name)}, Tok: token.DEFINE, Rhs: []ast.Expr{value}}
+}
+
+// boxParameters boxes the parameters that are captured, at the start of the function body
+func boxParameters(funcType *ast.FuncType, body *ast.BlockStmt, isBoxed func(*ast.Ident) bool, info *types.Info) {
+	if body == nil || funcType.Params == nil {
+		return
+	}
+	var boxes []ast.Stmt
+	for _, field := range funcType.Params.List {
+		for _, id := range field.Names {
+			if isBoxed(id) {
+				boxes = append(boxes, unboxedCopy(id, info))
+			}
+		}
+	}
+	body.List = append(boxes, body.List...)
+}
+
+// boxStatements declares the variables that are captured as boxes, in the given statements
+func boxStatements(list []ast.Stmt, isBoxed func(*ast.Ident) bool, info *types.Info) []ast.Stmt {
+	var result []ast.Stmt
+	for _, stmt := range list {
+		switch s := stmt.(type) {
+		case *ast.AssignStmt:
+			if s.Tok != token.DEFINE {
+				break
+			}
+			var after []ast.Stmt
+			for i, lhs := range s.Lhs {
+				id, ok := lhs.(*ast.Ident)
+				if !ok || !isBoxed(id) {
+					continue
+				}
+				if len(s.Lhs) == len(s.Rhs) {
+					s.Rhs[i] = box(id, s.Rhs[i], info)
+				} else {
+					// a, b := f()
+					after = append(after, unboxedCopy(id, info))
+				}
+			}
+			result = append(result, s)
+			result = append(result, after...)
+			continue
+		case *ast.DeclStmt:
+			decl, ok := s.Decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.VAR {
+				break
+			}
+			for _, spec := range decl.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, id := range vs.Names {
+					var value ast.Expr
+					if len(vs.Values) == len(vs.Names) {
+						value = vs.Values[i]
+					}
+					if isBoxed(id) {
+						result = append(result, &ast.AssignStmt{Lhs: []ast.Expr{id}, Tok: token.DEFINE, Rhs: []ast.Expr{box(id, value, info)}})
+						continue
+					}
+					single := &ast.ValueSpec{Names: []*ast.Ident{id}, Type: vs.Type}
+					if value != nil {
+						single.Values = []ast.Expr{value}
+					} else if len(vs.Values) > 0 {
+						// var a, b = f(), where none of the variables are boxed
+						single = vs
+					}
+					result = append(result, &ast.DeclStmt{Decl: &ast.GenDecl{TokPos: decl.TokPos, Tok: token.VAR, Specs: []ast.Spec{single}}})
+					if single == vs {
+						break
+					}
+				}
+			}
+			continue
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// reboxLoopVariable transforms the post statement of a for loop, so that a
+// boxed loop variable gets a new box before it is changed: (*i)++ -> _go_rebox(i)++
+func reboxLoopVariable(post ast.Stmt) {
+	rebox := func(e ast.Expr) ast.Expr {
+		if paren, ok := e.(*ast.ParenExpr); ok {
+			if star, ok := paren.X.(*ast.StarExpr); ok {
+				if id, ok := star.X.(*ast.Ident); ok {
+					return &ast.CallExpr{Fun: ast.NewIdent("_go_rebox"), Args: []ast.Expr{id}}
+				}
+			}
+		}
+		return e
+	}
+	switch s := post.(type) {
+	case *ast.IncDecStmt:
+		s.X = rebox(s.X)
+	case *ast.AssignStmt:
+		for i, lhs := range s.Lhs {
+			s.Lhs[i] = rebox(lhs)
+		}
+	}
+}
+
+// replaceExprs replaces the expressions within the given node with the result of f.
+// The calls of defer and go statements are only replaced with other calls.
+func replaceExprs(node ast.Node, f func(ast.Expr) ast.Expr) {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Type() == exprType:
+			if !field.IsNil() {
+				e := field.Interface().(ast.Expr)
+				replaceExprs(e, f)
+				field.Set(reflect.ValueOf(f(e)))
+			}
+		case field.Type() == callExprType:
+			if !field.IsNil() {
+				e := field.Interface().(*ast.CallExpr)
+				replaceExprs(e, f)
+				if c, ok := f(e).(*ast.CallExpr); ok {
+					field.Set(reflect.ValueOf(c))
+				}
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem() == exprType:
+			for j := 0; j < field.Len(); j++ {
+				e := field.Index(j).Interface().(ast.Expr)
+				replaceExprs(e, f)
+				field.Index(j).Set(reflect.ValueOf(f(e)))
+			}
+		case field.Type().Implements(nodeType) && (field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface):
+			if !field.IsNil() {
+				replaceExprs(field.Interface().(ast.Node), f)
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			for j := 0; j < field.Len(); j++ {
+				if !field.Index(j).IsNil() {
+					replaceExprs(field.Index(j).Interface().(ast.Node), f)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+)
+
+// Complex rewrites the complex numbers to calls of the functions that make
+// and take apart std::complex values in the generated C++ code:
+//
+//	c := 1 + 2i     ->  c := _go_complex(float64, 1.0, 2.0)
+//	complex(x, y)   ->  _go_complex(float64, x, y)
+//	real(c)         ->  _go_complex_real(c)
+//	imag(c)         ->  _go_complex_imag(c)
+//
+// The type argument is the type of the parts, which is float32 for complex64.
+// Constants that are used as complex numbers, like 2 in c * 2, are also made complex,
+// since std::complex can not be multiplied with an int.
+func Complex(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	checkSource(fset, file, info)
+
+	// partType returns the type of the real and imaginary parts of the given complex type, or ""
+	partType := func(t types.Type) string {
+		if t == nil {
+			return ""
+		}
+		if b, ok := types.Default(t).Underlying().(*types.Basic); ok {
+			switch b.Kind() {
+			case types.Complex64:
+				return "float32"
+			case types.Complex128:
+				return "float64"
+			}
+		}
+		return ""
+	}
+
+	changed := false
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		tv := info.Types[e]
+		if part := partType(tv.Type); part != "" && tv.Value != nil {
+			re := constantExpr(constant.Real(tv.Value), types.Typ[types.Float64], false)
+			im := constantExpr(constant.Imag(tv.Value), types.Typ[types.Float64], false)
+			if re != "" && im != "" {
+				changed = true
+				return parseGenerated("_go_complex("+part+", "+re+", "+im+")", e.Pos())
+			}
+		}
+		c, ok := e.(*ast.CallExpr)
+		if !ok {
+			return e
+		}
+		id, ok := c.Fun.(*ast.Ident)
+		if !ok || !info.Types[id].IsBuiltin() {
+			return e
+		}
+		switch id.Name {
+		case "complex":
+			if part := partType(tv.Type); part != "" && len(c.Args) == 2 {
+				changed = true
+				return call("_go_complex", e, append([]ast.Expr{&ast.Ident{NamePos: c.Pos(), Name: part}}, c.Args...)...)
+			}
+		case "real", "imag":
+			changed = true
+			return call("_go_complex_"+id.Name, e, c.Args...)
+		}
+		return e
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
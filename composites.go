@@ -0,0 +1,213 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// CompositeLiterals rewrites the composite literals to forms that the line by line
+// translation can handle, with all the types given and all the elements on one line:
+//
+//	[]Point{{1, 2}, {3, 4}}      ->  []Point{Point{1, 2}, Point{3, 4}}
+//	[]*Point{{1, 2}}             ->  []*Point{&Point{1, 2}}
+//	map[string]Point{"a": {1}}   ->  map[string]Point{"a": Point{1}}
+//	Point{Y: 2, X: 1}            ->  Point{X: 1, Y: 2}
+//	[]int{2: 5, 7}               ->  []int{0, 0, 5, 7}
+//	[]string{                    ->  []string{"a", "b"}
+//	    "a",
+//	    "b",
+//	}
+//
+// The keyed fields of struct literals are sorted in the order that the fields are declared
+// in, since C++ requires that, and the fields that are left out get their zero values.
+// Literals that contain function literals are left on several lines.
+func CompositeLiterals(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	checkSource(fset, file, info)
+
+	changed := false
+	// elided gives the type to a literal that is an element of another literal, where the
+	// type may be left out, and the & for the literals that are pointers
+	elided := func(e ast.Expr, t types.Type) ast.Expr {
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok || lit.Type != nil || t == nil {
+			return e
+		}
+		changed = true
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			lit.Type = parseGenerated(goTypeString(p.Elem()), lit.Lbrace)
+			return &ast.UnaryExpr{OpPos: lit.Lbrace, Op: token.AND, X: lit}
+		}
+		lit.Type = parseGenerated(goTypeString(t), lit.Lbrace)
+		return lit
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		t := info.Types[lit].Type
+		if t == nil {
+			return true
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Struct:
+			if sortFields(lit, u) {
+				changed = true
+			}
+		case *types.Map:
+			for _, elt := range lit.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					kv.Key = elided(kv.Key, u.Key())
+					kv.Value = elided(kv.Value, u.Elem())
+				}
+			}
+		case *types.Slice:
+			if indexed(lit, u.Elem(), info) {
+				changed = true
+			}
+			for i, elt := range lit.Elts {
+				lit.Elts[i] = elided(elt, u.Elem())
+			}
+		case *types.Array:
+			if indexed(lit, u.Elem(), info) {
+				changed = true
+			}
+			for i, elt := range lit.Elts {
+				lit.Elts[i] = elided(elt, u.Elem())
+			}
+		}
+		return true
+	})
+
+	// Place the literals that span several lines on one line
+	var comments []*ast.CommentGroup
+	inside := func(c *ast.CommentGroup, lits []*ast.CompositeLit) bool {
+		for _, lit := range lits {
+			if c.Pos() > lit.Lbrace && c.End() < lit.Rbrace {
+				return true
+			}
+		}
+		return false
+	}
+	var multiline []*ast.CompositeLit
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok || fset.Position(lit.Lbrace).Line == fset.Position(lit.Rbrace).Line || hasFuncLit(lit) {
+			return e
+		}
+		changed = true
+		multiline = append(multiline, lit)
+		return parseGenerated(exprString(lit), lit.Pos())
+	})
+	for _, c := range file.Comments {
+		// The comments inside of the literals that are placed on one line are removed
+		if !inside(c, multiline) {
+			comments = append(comments, c)
+		}
+	}
+	file.Comments = comments
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// sortFields sorts the keyed fields of a struct literal in the order that the fields are
+// declared in. Returns true if the order is changed.
+func sortFields(lit *ast.CompositeLit, s *types.Struct) bool {
+	index := func(elt ast.Expr) int {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if id, ok := kv.Key.(*ast.Ident); ok {
+				for i := 0; i < s.NumFields(); i++ {
+					if s.Field(i).Name() == id.Name {
+						return i
+					}
+				}
+			}
+		}
+		return -1
+	}
+	if sort.SliceIsSorted(lit.Elts, func(i, j int) bool { return index(lit.Elts[i]) < index(lit.Elts[j]) }) {
+		return false
+	}
+	sort.SliceStable(lit.Elts, func(i, j int) bool { return index(lit.Elts[i]) < index(lit.Elts[j]) })
+	return true
+}
+
+// indexed gives the elements of an array or slice literal that has indices as keys, like
+// []int{2: 5, 7}, without the keys, and with zero values for the indices that are left out.
+// Returns true if the literal has indices.
+func indexed(lit *ast.CompositeLit, elem types.Type, info *types.Info) bool {
+	hasKeys := false
+	for _, elt := range lit.Elts {
+		if _, ok := elt.(*ast.KeyValueExpr); ok {
+			hasKeys = true
+		}
+	}
+	if !hasKeys {
+		return false
+	}
+	values := make(map[int64]ast.Expr)
+	var index, length int64
+	for _, elt := range lit.Elts {
+		value := elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if i, exact := constant.Int64Val(constant.ToInt(info.Types[kv.Key].Value)); exact {
+				index = i
+			}
+			value = kv.Value
+		}
+		values[index] = value
+		index++
+		if index > length {
+			length = index
+		}
+	}
+	elts := make([]ast.Expr, length)
+	for i := range elts {
+		if value, ok := values[int64(i)]; ok {
+			elts[i] = value
+		} else {
+			elts[i] = parseGenerated(zeroValue(elem), lit.Lbrace)
+		}
+	}
+	lit.Elts = elts
+	return true
+}
+
+// zeroValue returns the zero value of the given type, as a Go expression
+func zeroValue(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Kind() == types.UnsafePointer:
+			return "nil"
+		}
+		return "0"
+	case *types.Struct, *types.Array:
+		return goTypeString(t) + "{}"
+	}
+	return "nil"
+}
+
+// hasFuncLit checks if the given node contains a function literal
+func hasFuncLit(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Constants evaluates the constants like Go does, with arbitrary precision, and gives the
+// declarations of the constants explicit values, one for each name:
+//
+//	const (                         ->  const (
+//	    KB = 1 << (10 * (iota + 1))  ->      KB = 1024
+//	    MB                          ->      MB = 1048576
+//	)                               ->  )
+//	const big = 1 << 100            ->  (removed)
+//	x := big >> 98                  ->  x := 4
+//
+// The constant expressions are replaced with their values, with a conversion if the type
+// is not the default type of the value. The untyped constants that do not fit in their
+// default type, like big, can only be used in constant expressions, so they are removed.
+func Constants(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	// The constants that are declared, and not removed
+	declared := func(obj types.Object) bool {
+		c, ok := obj.(*types.Const)
+		return ok && c.Pkg() == pkg && pkg != nil && c.Parent() != types.Universe && representable(c.Val(), types.Default(c.Type()))
+	}
+
+	changed := false
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		tv, ok := info.Types[e]
+		if !ok || tv.Value == nil {
+			return e
+		}
+		switch x := e.(type) {
+		case *ast.BasicLit:
+			return e
+		case *ast.Ident:
+			if x.Name == "true" || x.Name == "false" || declared(info.Uses[x]) {
+				return e
+			}
+		}
+		value := constantExpr(tv.Value, tv.Type, true)
+		if value == "" {
+			return e
+		}
+		changed = true
+		return parseGenerated(value, e.Pos())
+	})
+
+	// The declarations of the constants
+	constDecl := func(decl ast.Decl) bool {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.CONST {
+			return false
+		}
+		changed = true
+		var specs []ast.Spec
+		for _, spec := range d.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for _, id := range vs.Names {
+				c, ok := info.Defs[id].(*types.Const)
+				if !ok || !declared(c) || isBlank(id) {
+					continue
+				}
+				newSpec := &ast.ValueSpec{Doc: vs.Doc, Names: []*ast.Ident{id}, Comment: vs.Comment}
+				// Untyped constants are declared with their default type, unless the type
+				// of the value is the same in C++, like for 1, 2.5, true and "abc"
+				t := c.Type()
+				if b, ok := t.(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+					t = types.Default(t)
+				}
+				switch t {
+				case types.Typ[types.Bool], types.Typ[types.String], types.Typ[types.Int], types.Typ[types.Float64]:
+					if c.Type() != t {
+						break
+					}
+					fallthrough
+				default:
+					newSpec.Type = parseGenerated(goTypeString(t), id.Pos())
+				}
+				value := constantExpr(c.Val(), t, false)
+				if value == "" {
+					// The constants that can not be given as literals are declared as they are
+					if len(vs.Values) == 0 {
+						continue
+					}
+					newSpec = vs
+				} else {
+					newSpec.Values = []ast.Expr{parseGenerated(value, id.Pos())}
+				}
+				specs = append(specs, newSpec)
+				vs.Doc = nil
+			}
+		}
+		d.Specs = specs
+		if len(specs) <= 1 {
+			d.Lparen, d.Rparen = token.NoPos, token.NoPos
+		}
+		return len(specs) == 0
+	}
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		if !constDecl(decl) {
+			decls = append(decls, decl)
+		}
+	}
+	file.Decls = decls
+	removeDecls := func(list []ast.Stmt) []ast.Stmt {
+		var result []ast.Stmt
+		for _, stmt := range list {
+			if ds, ok := stmt.(*ast.DeclStmt); ok && constDecl(ds.Decl) {
+				continue
+			}
+			result = append(result, stmt)
+		}
+		return result
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			n.List = removeDecls(n.List)
+		case *ast.CaseClause:
+			n.Body = removeDecls(n.Body)
+		case *ast.CommClause:
+			n.Body = removeDecls(n.Body)
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// representable checks if the given constant value can be declared with the given type in C++
+func representable(value constant.Value, t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	if basic.Info()&types.IsInteger == 0 {
+		return true
+	}
+	if i, exact := constant.Int64Val(value); exact {
+		return basic.Info()&types.IsUnsigned == 0 || i >= 0
+	}
+	_, exact := constant.Uint64Val(value)
+	return exact && basic.Info()&types.IsUnsigned != 0
+}
+
+// constantExpr returns the given constant value as a Go expression of the given type. If convert
+// is true, the value is converted to the type, unless it is the default type of the value.
+// Returns "" for the values that are not replaced, like infinite floating point numbers.
+func constantExpr(value constant.Value, t types.Type, convert bool) string {
+	t = types.Default(t)
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	var s string
+	switch info := basic.Info(); {
+	case info&types.IsBoolean != 0:
+		s = strconv.FormatBool(constant.BoolVal(value))
+	case info&types.IsString != 0:
+		s = strconv.Quote(constant.StringVal(value))
+	case info&types.IsInteger != 0:
+		v := constant.ToInt(value)
+		if i, exact := constant.Int64Val(v); exact && i == math.MinInt64 {
+			s = "(-9223372036854775807 - 1)"
+		} else if _, exact := constant.Int64Val(v); exact {
+			s = v.ExactString()
+		} else if u, exact := constant.Uint64Val(v); exact {
+			// Hexadecimal literals that are too large for a signed integer are unsigned in C++
+			s = "0x" + strconv.FormatUint(u, 16)
+		} else {
+			return ""
+		}
+	case info&types.IsFloat != 0:
+		f, _ := constant.Float64Val(constant.ToFloat(value))
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return ""
+		}
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+	case info&types.IsComplex != 0:
+		value = constant.ToComplex(value)
+		re := constantExpr(constant.Real(value), types.Typ[types.Float64], false)
+		im := constantExpr(constant.Imag(value), types.Typ[types.Float64], false)
+		if re == "" || im == "" {
+			return ""
+		}
+		s = "complex(" + re + ", " + im + ")"
+	default:
+		return ""
+	}
+	if !convert {
+		return s
+	}
+	switch t {
+	case types.Typ[types.Bool], types.Typ[types.String], types.Typ[types.Int], types.Typ[types.Float64], types.Typ[types.Complex128]:
+		return s
+	}
+	return goTypeString(t) + "(" + s + ")"
+}
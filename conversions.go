@@ -0,0 +1,34 @@
+package main
+
+import (
+	"go/ast"
+)
+
+// numericTypes are the predeclared numeric types in Go
+var numericTypes = []string{"int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune", "float32", "float64", "complex64", "complex128"}
+
+// Conversions rewrites the conversions to the predeclared numeric types, since
+// not all of the types have names that C++ allows in a conversion, like "unsigned int":
+//
+//	int64(x)  ->  _go_convert(int64, x)
+func Conversions(source string) string {
+	fset, file := parseSource(source)
+	changed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		c, ok := n.(*ast.CallExpr)
+		if !ok || len(c.Args) != 1 {
+			return true
+		}
+		if id, ok := c.Fun.(*ast.Ident); ok && has(numericTypes, id.Name) {
+			c.Fun = &ast.Ident{NamePos: id.Pos(), Name: "_go_convert"}
+			c.Args = append([]ast.Expr{id}, c.Args...)
+			changed = true
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Declarations moves the type and constant declarations to the start of the program, after
+// the imports, and declares the functions after them, and then the variables, since C++
+// needs them to be declared before they are used, while Go does not:
+//
+//	import "fmt"                    ->  import "fmt"
+//	                                ->
+//	func main() {                   ->  type Meta struct {
+//	    ...                         ->      name string
+//	}                               ->  }
+//	                                ->  type List struct {
+//	type List struct {              ->      head *Node
+//	    head *Node                  ->      meta Meta
+//	    meta Meta                   ->  }
+//	}                               ->
+//	                                ->  func isEven(n int) bool
+//	type Meta struct {              ->
+//	    name string                 ->  func main() {
+//	}                               ->      ...
+//	                                ->  }
+//	func isEven(n int) bool {       ->
+//	    ...                         ->  func isEven(n int) bool {
+//	}                               ->      ...
+//
+// The types are ordered so that the types that are used by value, like the fields of
+// structs and the elements of arrays, are declared before the types that use them.
+// Pointers only need the type to be declared, which TranslateLines does for all structs.
+// The constants are ordered together with the types, since the types of constants must
+// be declared before them, and the constants that are the lengths of arrays must be
+// declared before the types of the arrays.
+// The variables are in the order that Initialization has given them, and come after the
+// function prototypes, since they may be initialized by calling the functions.
+// The functions without a body are translated to function prototypes. Type declarations
+// in groups are declared one by one. This is the last rewrite before the translation, so
+// the methods have already been rewritten to functions.
+func Declarations(source string) string {
+	fset, file := parseSource(source)
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+
+	// The type declarations, by name, the constant and variable declarations,
+	// the function prototypes, and the end of the imports
+	specs := map[string]*ast.TypeSpec{}
+	docs := map[string]*ast.CommentGroup{}
+	var names []string
+	var typeDecls, constDecls, varDecls []*ast.GenDecl
+	var prototypes []string
+	headerEnd := offset(file.Name.End())
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			switch d.Tok {
+			case token.IMPORT:
+				headerEnd = offset(d.End())
+			case token.CONST:
+				constDecls = append(constDecls, d)
+			case token.VAR:
+				varDecls = append(varDecls, d)
+			case token.TYPE:
+				typeDecls = append(typeDecls, d)
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if ts.Name.Name == "_" {
+						continue
+					}
+					specs[ts.Name.Name] = ts
+					docs[ts.Name.Name] = ts.Doc
+					if !d.Lparen.IsValid() {
+						docs[ts.Name.Name] = d.Doc
+					}
+					names = append(names, ts.Name.Name)
+				}
+			}
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil || d.Body == nil || d.Type.TypeParams != nil || name == "main" || name == "init" || name == "_" {
+				continue
+			}
+			prototypes = append(prototypes, "func "+name+source[offset(d.Type.Params.Pos()):offset(d.Type.End())])
+		}
+	}
+	if len(typeDecls) == 0 && len(constDecls) == 0 && len(varDecls) == 0 && len(prototypes) == 0 {
+		return source
+	}
+
+	// The constant declarations, by the names of the constants
+	constants := map[string]*ast.GenDecl{}
+	for _, d := range constDecls {
+		for _, spec := range d.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				constants[name.Name] = d
+			}
+		}
+	}
+
+	// The types and constants that are used by a type or constant come first,
+	// in the source code that declares them
+	var ordered []string
+	visitedTypes := map[string]bool{}
+	visitedConstants := map[*ast.GenDecl]bool{}
+	var visitType func(name string)
+	var visitConstants func(d *ast.GenDecl)
+	visitUsed := func(used []string) {
+		for _, name := range used {
+			if _, ok := specs[name]; ok {
+				visitType(name)
+			} else if d, ok := constants[name]; ok {
+				visitConstants(d)
+			}
+		}
+	}
+	visitType = func(name string) {
+		if visitedTypes[name] {
+			return
+		}
+		visitedTypes[name] = true
+		visitUsed(typesUsedByValue(specs[name].Type))
+		ts := specs[name]
+		declaration := "type " + source[offset(ts.Name.Pos()):offset(ts.End())]
+		if doc := docs[name]; doc != nil {
+			declaration = source[offset(doc.Pos()):offset(doc.End())] + "\n" + declaration
+		}
+		ordered = append(ordered, declaration)
+	}
+	visitConstants = func(d *ast.GenDecl) {
+		if visitedConstants[d] {
+			return
+		}
+		visitedConstants[d] = true
+		visitUsed(typesUsedByValue(d))
+		ordered = append(ordered, declarationSource(source, fset, d))
+	}
+	for _, name := range names {
+		visitType(name)
+	}
+	for _, d := range constDecls {
+		visitConstants(d)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(source[:headerEnd])
+	sb.WriteString("\n\n")
+	for _, declaration := range ordered {
+		sb.WriteString(declaration + "\n\n")
+	}
+	moved := map[*ast.GenDecl]bool{}
+	for _, d := range constDecls {
+		moved[d] = true
+	}
+	for _, prototype := range prototypes {
+		sb.WriteString(prototype + "\n")
+	}
+	for _, d := range varDecls {
+		sb.WriteString("\n" + declarationSource(source, fset, d) + "\n")
+		moved[d] = true
+	}
+	for _, d := range typeDecls {
+		moved[d] = true
+	}
+
+	// The rest of the source code, without the declarations that have been moved
+	pos := headerEnd
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || !moved[d] {
+			continue
+		}
+		start := offset(d.Pos())
+		if d.Doc != nil {
+			start = offset(d.Doc.Pos())
+		}
+		sb.WriteString(source[pos:start])
+		pos = offset(d.End())
+	}
+	sb.WriteString(source[pos:])
+
+	return formatSource(sb.String())
+}
+
+// declarationSource returns the source code of the given declaration, with its documentation
+func declarationSource(source string, fset *token.FileSet, d *ast.GenDecl) string {
+	start := d.Pos()
+	if d.Doc != nil {
+		start = d.Doc.Pos()
+	}
+	return source[fset.Position(start).Offset:fset.Position(d.End()).Offset]
+}
+
+// typesUsedByValue returns the names of the types that the given type expression or
+// declaration contains values of, and the other identifiers in it, like the constants
+// that are the lengths of arrays. The types that are only pointed to, or that are in the
+// signatures of functions and methods, are left out.
+func typesUsedByValue(e ast.Node) []string {
+	var names []string
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.StarExpr, *ast.FuncType:
+			return false
+		case *ast.SelectorExpr:
+			// A type in another package
+			return false
+		case *ast.Ident:
+			names = append(names, x.Name)
+		}
+		return true
+	})
+	return names
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// labelErrors are the start of the errors from the type checker that are about
+// labels, and the goto, break and continue statements that refer to them
+var labelErrors = []string{"goto ", "label ", "invalid break label ", "invalid continue label "}
+
+// constantErrors are parts of the errors from the type checker that are about constants
+// that overflow or are truncated, or that can not be evaluated
+var constantErrors = []string{"(overflows)", " overflows ", "(truncated)", " truncated ", "division by zero", "must be integer", " constant) to type "}
+
+// typeErrors are the start of the errors from the type checker that are about types that
+// can not be declared, like structs that contain themselves and maps with keys that can
+// not be compared
+var typeErrors = []string{"invalid recursive type", "invalid map key type "}
+
+// reportedError checks if the given error from the type checker is reported by go2cpp
+func reportedError(msg string) bool {
+	for _, prefix := range labelErrors {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	for _, prefix := range typeErrors {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	for _, part := range constantErrors {
+		if strings.Contains(msg, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckErrors checks the source code for the errors that the Go compiler reports, but that
+// C++ compilers would not report, or that can not be reported after the translation:
+//
+//   - Labels, and the goto, break and continue statements that refer to them.
+//     C++ allows some jumps that Go does not, like jumping over variable declarations.
+//   - Constants that overflow their types, since constants are evaluated by go2cpp.
+//   - Types that contain themselves, since the types are ordered by what they contain.
+//
+// The errors are reported with their line and column, and then go2cpp exits.
+func CheckErrors(source string) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var errors []string
+	reported := false // if the last error was reported, for the errors that continue it
+	conf := types.Config{Importer: packageImporter, Error: func(err error) {
+		e, ok := err.(types.Error)
+		if !ok {
+			return
+		}
+		pos := fset.Position(e.Pos)
+		if strings.HasPrefix(e.Msg, "\t") && reported {
+			// Like "\tA refers to B", after "invalid recursive type A"
+			errors = append(errors, "\t"+strconv.Itoa(pos.Line)+":"+strconv.Itoa(pos.Column)+": "+e.Msg[1:])
+			return
+		}
+		reported = reportedError(e.Msg)
+		if reported {
+			errors = append(errors, strconv.Itoa(pos.Line)+":"+strconv.Itoa(pos.Column)+": "+e.Msg)
+		}
+	}}
+	conf.Check("main", fset, []*ast.File{file}, nil)
+	if len(errors) > 0 {
+		log.Fatalln(strings.Join(errors, "\n"))
+	}
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const stackPrefix = "_go_stack_"
+
+// escapeNode is something that may escape from the function that it is made in: a value
+// that is allocated by &T{...} or new(T), a local variable, or the values that the
+// pointers in a local variable point to
+type escapeNode struct {
+	site     ast.Expr // &T{...} or new(T), or nil for variables
+	variable *types.Var
+	contents bool
+}
+
+// escapeAnalysis finds the values that are used after the function that makes them
+// returns, like values that are returned, stored in package level variables, stored
+// through pointers, or given to functions that let them escape. The values that do not
+// escape can be placed on the stack. It is like the escape analysis of the Go compiler,
+// but simpler, and it assumes that a value escapes when it is not sure.
+type escapeAnalysis struct {
+	info *types.Info
+	pkg  *types.Package
+	// into has the nodes that flow into a node, which escape if the node escapes
+	into map[escapeNode][]escapeNode
+	// reasons has why a node escapes, for the nodes that escape
+	reasons map[escapeNode]string
+	// sites are the allocations by &T{...} and new(T), in the order of the source code
+	sites []ast.Expr
+	// addressed are the local variables that have their address taken
+	addressed map[*types.Var]bool
+}
+
+// analyzeEscapes finds the values in the given file that escape
+func analyzeEscapes(file *ast.File, info *types.Info, pkg *types.Package) *escapeAnalysis {
+	a := &escapeAnalysis{
+		info:      info,
+		pkg:       pkg,
+		into:      map[escapeNode][]escapeNode{},
+		reasons:   map[escapeNode]string{},
+		addressed: map[*types.Var]bool{},
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if e, ok := n.(ast.Expr); ok && a.isSite(e) {
+			a.sites = append(a.sites, e)
+			if u, ok := e.(*ast.UnaryExpr); ok {
+				// The values in the literal escape if the literal does
+				a.flow(a.flows(u.X), escapeNode{site: e})
+			}
+		}
+		return true
+	})
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Type.Results != nil {
+				for _, field := range d.Type.Results.List {
+					for _, id := range field.Names {
+						if v, ok := info.Defs[id].(*types.Var); ok {
+							a.escape([]escapeNode{{variable: v, contents: true}}, "returned from "+d.Name.Name)
+						}
+					}
+				}
+			}
+			if d.Body != nil {
+				a.walk(d.Body, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			a.walk(d, "")
+		}
+	}
+
+	// The nodes that flow into a node that escapes also escape
+	var work []escapeNode
+	for n := range a.reasons {
+		work = append(work, n)
+	}
+	for len(work) > 0 {
+		n := work[len(work)-1]
+		work = work[:len(work)-1]
+		reason := a.reasons[n]
+		if n.variable != nil && n.site == nil && !strings.Contains(reason, " (through ") {
+			reason += " (through " + n.variable.Name() + ")"
+		}
+		for _, from := range a.into[n] {
+			if _, ok := a.reasons[from]; !ok {
+				a.reasons[from] = reason
+				work = append(work, from)
+			}
+		}
+	}
+	return a
+}
+
+// isSite checks if the given expression is &T{...} or new(T)
+func (a *escapeAnalysis) isSite(e ast.Expr) bool {
+	switch x := e.(type) {
+	case *ast.UnaryExpr:
+		_, ok := x.X.(*ast.CompositeLit)
+		return ok && x.Op == token.AND
+	case *ast.CallExpr:
+		if id, ok := x.Fun.(*ast.Ident); ok && len(x.Args) == 1 {
+			b, ok := a.info.Uses[id].(*types.Builtin)
+			return ok && b.Name() == "new"
+		}
+	}
+	return false
+}
+
+// flow records that the given nodes flow into another node
+func (a *escapeAnalysis) flow(from []escapeNode, to escapeNode) {
+	a.into[to] = append(a.into[to], from...)
+}
+
+// escape records that the given nodes escape, for the given reason
+func (a *escapeAnalysis) escape(nodes []escapeNode, reason string) {
+	for _, n := range nodes {
+		if _, ok := a.reasons[n]; !ok {
+			a.reasons[n] = reason
+		}
+		if n.variable != nil && !n.contents {
+			a.escape([]escapeNode{{variable: n.variable, contents: true}}, reason)
+		}
+	}
+}
+
+// local returns the local variable that the given identifier refers to, or nil
+func (a *escapeAnalysis) local(id *ast.Ident) *types.Var {
+	v, ok := a.info.ObjectOf(id).(*types.Var)
+	if !ok || !isLocal(v) {
+		return nil
+	}
+	return v
+}
+
+// isPointer checks if the given expression is a pointer. Expressions without a known type are pointers.
+func (a *escapeAnalysis) isPointer(e ast.Expr) bool {
+	t := a.info.TypeOf(e)
+	if t == nil {
+		return true
+	}
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+// isArray checks if the given expression is an array
+func (a *escapeAnalysis) isArray(e ast.Expr) bool {
+	if t := a.info.TypeOf(e); t != nil {
+		_, ok := t.Underlying().(*types.Array)
+		return ok
+	}
+	return false
+}
+
+// flows returns the nodes that the pointers in the value of the given expression may point to
+func (a *escapeAnalysis) flows(e ast.Expr) []escapeNode {
+	switch x := e.(type) {
+	case *ast.Ident:
+		if v := a.local(x); v != nil {
+			return []escapeNode{{variable: v, contents: true}}
+		}
+	case *ast.ParenExpr:
+		return a.flows(x.X)
+	case *ast.UnaryExpr:
+		if x.Op == token.AND {
+			if a.isSite(x) {
+				return []escapeNode{{site: x}}
+			}
+			return a.addressFlows(x.X)
+		}
+	case *ast.CallExpr:
+		if a.isSite(x) {
+			return []escapeNode{{site: x}}
+		}
+		if tv := a.info.Types[x.Fun]; tv.IsType() && len(x.Args) == 1 {
+			return a.flows(x.Args[0])
+		}
+	case *ast.SliceExpr:
+		if a.isArray(x.X) {
+			return a.addressFlows(x.X)
+		}
+		return a.flows(x.X)
+	case *ast.SelectorExpr:
+		if sel := a.info.Selections[x]; sel != nil && sel.Kind() == types.FieldVal && !a.isPointer(x.X) {
+			return a.flows(x.X)
+		}
+	case *ast.IndexExpr:
+		// The elements of arrays, slices and maps are a part of the value
+		if !a.isPointer(x.X) {
+			return a.flows(x.X)
+		}
+	case *ast.CompositeLit:
+		var nodes []escapeNode
+		for _, elt := range x.Elts {
+			nodes = append(nodes, a.flows(elt)...)
+		}
+		return nodes
+	case *ast.KeyValueExpr:
+		return append(a.flows(x.Key), a.flows(x.Value)...)
+	case *ast.TypeAssertExpr:
+		return a.flows(x.X)
+	}
+	return nil
+}
+
+// addressFlows returns the nodes that the address of the given expression points into
+func (a *escapeAnalysis) addressFlows(e ast.Expr) []escapeNode {
+	switch x := e.(type) {
+	case *ast.Ident:
+		if v := a.local(x); v != nil {
+			storage := escapeNode{variable: v}
+			if !a.addressed[v] {
+				a.addressed[v] = true
+				// The pointers in a variable can be read by those that have its address
+				a.flow([]escapeNode{{variable: v, contents: true}}, storage)
+			}
+			return []escapeNode{storage}
+		}
+	case *ast.ParenExpr:
+		return a.addressFlows(x.X)
+	case *ast.SelectorExpr:
+		if a.isPointer(x.X) {
+			return a.flows(x.X)
+		}
+		return a.addressFlows(x.X)
+	case *ast.IndexExpr:
+		if a.isArray(x.X) {
+			return a.addressFlows(x.X)
+		}
+		return a.flows(x.X)
+	case *ast.StarExpr:
+		return a.flows(x.X)
+	case *ast.CompositeLit:
+		return a.flows(x)
+	}
+	return nil
+}
+
+// assign records that the given nodes are assigned to the given expression
+func (a *escapeAnalysis) assign(lhs ast.Expr, nodes []escapeNode) {
+	if len(nodes) == 0 || isBlank(lhs) {
+		return
+	}
+	if id, ok := lhs.(*ast.Ident); ok && a.local(id) == nil {
+		a.escape(nodes, "assigned to package level variable "+id.Name)
+		return
+	}
+	if v := addressedVariable(lhs, a.info); v != nil && isLocal(v) {
+		// A local variable, or a field or element of one
+		a.flow(nodes, escapeNode{variable: v, contents: true})
+		return
+	}
+	a.escape(nodes, "assigned to "+exprString(lhs))
+}
+
+// call records what happens to the arguments of a call
+func (a *escapeAnalysis) call(c *ast.CallExpr) {
+	if tv := a.info.Types[c.Fun]; tv.IsType() {
+		return
+	}
+	if id, ok := ast.Unparen(c.Fun).(*ast.Ident); ok {
+		if _, ok := a.info.Uses[id].(*types.Builtin); ok {
+			switch id.Name {
+			case "append":
+				if !c.Ellipsis.IsValid() {
+					for _, arg := range c.Args[1:] {
+						a.escape(a.flows(arg), "appended to a slice")
+					}
+				}
+			case "panic":
+				a.escape(a.flows(c.Args[0]), "given to panic")
+			}
+			return
+		}
+	}
+	args := c.Args
+	var callee *types.Func
+	var receiver *types.Var
+	switch f := ast.Unparen(c.Fun).(type) {
+	case *ast.Ident:
+		callee, _ = a.info.Uses[f].(*types.Func)
+	case *ast.SelectorExpr:
+		if sel := a.info.Selections[f]; sel != nil {
+			switch {
+			case sel.Kind() == types.MethodVal && methodFunction(sel.Obj(), a.pkg) != "" && len(sel.Index()) == 1:
+				callee = sel.Obj().(*types.Func)
+				receiver = callee.Type().(*types.Signature).Recv()
+				a.flow(a.receiverFlows(f.X, sel), escapeNode{variable: receiver, contents: true})
+			case sel.Kind() == types.MethodVal:
+				a.escape(a.receiverFlows(f.X, sel), "given to "+exprString(f))
+			}
+		} else {
+			callee, _ = a.info.Uses[f.Sel].(*types.Func)
+		}
+	}
+	if callee == nil || callee.Pkg() != a.pkg || a.pkg == nil {
+		for _, arg := range args {
+			a.escape(a.flows(arg), "given to "+exprString(c.Fun))
+		}
+		return
+	}
+	// The arguments are assigned to the parameters of a function in this package
+	params := callee.Type().(*types.Signature).Params()
+	for i, arg := range args {
+		if params.Len() == 0 {
+			break
+		}
+		param := params.At(params.Len() - 1)
+		if i < params.Len() {
+			param = params.At(i)
+		}
+		a.flow(a.flows(arg), escapeNode{variable: param, contents: true})
+	}
+}
+
+// receiverFlows returns the nodes that the receiver of a method call may point to
+func (a *escapeAnalysis) receiverFlows(x ast.Expr, sel *types.Selection) []escapeNode {
+	_, wantsPointer := sel.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer)
+	if wantsPointer && !a.isPointer(x) && len(sel.Index()) == 1 {
+		return a.addressFlows(x)
+	}
+	return a.flows(x)
+}
+
+// walk records the flows and escapes in the given node, that is in the function with the given name
+func (a *escapeAnalysis) walk(node ast.Node, function string) {
+	calls := map[ast.Expr]bool{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			// The variables that a function literal captures escape
+			ast.Inspect(x.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if v := a.local(id); v != nil && (v.Pos() < x.Pos() || v.Pos() >= x.End()) {
+						a.escape([]escapeNode{{variable: v}}, "captured by a function literal")
+					}
+				}
+				return true
+			})
+			a.walk(x.Body, "a function literal")
+			return false
+		case *ast.AssignStmt:
+			if (x.Tok == token.ASSIGN || x.Tok == token.DEFINE) && len(x.Lhs) == len(x.Rhs) {
+				for i, lhs := range x.Lhs {
+					a.assign(lhs, a.flows(x.Rhs[i]))
+				}
+			}
+		case *ast.ValueSpec:
+			if len(x.Names) == len(x.Values) {
+				for i, id := range x.Names {
+					a.assign(id, a.flows(x.Values[i]))
+				}
+			}
+		case *ast.RangeStmt:
+			if x.Value != nil && !a.isPointer(x.X) {
+				a.assign(x.Value, a.flows(x.X))
+			}
+		case *ast.ReturnStmt:
+			for _, result := range x.Results {
+				a.escape(a.flows(result), "returned from "+function)
+			}
+		case *ast.SendStmt:
+			a.escape(a.flows(x.Value), "sent to a channel")
+		case *ast.CallExpr:
+			calls[x.Fun] = true
+			a.call(x)
+		case *ast.SelectorExpr:
+			if sel := a.info.Selections[x]; sel != nil && sel.Kind() == types.MethodVal && !calls[x] {
+				a.escape(a.receiverFlows(x.X, sel), "bound to the method value "+exprString(x))
+			}
+		}
+		return true
+	})
+}
+
+// escapes returns why the given node escapes, or "" if it does not
+func (a *escapeAnalysis) escapes(n escapeNode) string {
+	return a.reasons[n]
+}
+
+// addressedVariable returns the variable that the given expression is a part of, if the
+// expression is a variable, or a field or an array element of one, or nil otherwise
+func addressedVariable(e ast.Expr, info *types.Info) *types.Var {
+	switch x := e.(type) {
+	case *ast.Ident:
+		v, _ := info.ObjectOf(x).(*types.Var)
+		return v
+	case *ast.ParenExpr:
+		return addressedVariable(x.X, info)
+	case *ast.SelectorExpr:
+		if t := info.TypeOf(x.X); t != nil {
+			if _, ok := t.Underlying().(*types.Pointer); !ok {
+				return addressedVariable(x.X, info)
+			}
+		}
+	case *ast.IndexExpr:
+		if t := info.TypeOf(x.X); t != nil {
+			if _, ok := t.Underlying().(*types.Array); ok {
+				return addressedVariable(x.X, info)
+			}
+		}
+	}
+	return nil
+}
+
+// StackAllocations places the values that are allocated by &T{...} and new(T), and that do
+// not escape from the function that allocates them, in local variables:
+//
+//	p := &Point{1, 2}  ->  _go_stack_0 := Point{1, 2}
+//	                       p := &_go_stack_0
+//	q := new(Point)    ->  var _go_stack_1 Point
+//	                       q := &_go_stack_1
+//
+// The local variables do not escape either, so they are not boxed by Closures, and the
+// generated C++ code places them on the stack. A value is only moved out of a statement
+// if that does not change what the statement does, like when the value is the whole right
+// hand side of an assignment, or is made of variables and constants.
+func StackAllocations(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	a := analyzeEscapes(file, info, pkg)
+	counter := 0
+	changed := false
+	stack := func(list []ast.Stmt) []ast.Stmt {
+		var result []ast.Stmt
+		for _, stmt := range list {
+			for _, site := range stackSites(stmt, a) {
+				name := stackPrefix + strconv.Itoa(counter)
+				counter++
+				switch x := site.expr.(type) {
+				case *ast.UnaryExpr:
+					lit := x.X.(*ast.CompositeLit)
+					result = append(result, &ast.AssignStmt{Lhs: []ast.Expr{&ast.Ident{NamePos: lit.Pos(), Name: name}}, Tok: token.DEFINE, TokPos: lit.Pos(), Rhs: []ast.Expr{lit}})
+				case *ast.CallExpr:
+					spec := &ast.ValueSpec{Names: []*ast.Ident{{NamePos: x.Pos(), Name: name}}, Type: x.Args[0]}
+					result = append(result, &ast.DeclStmt{Decl: &ast.GenDecl{TokPos: x.Pos(), Tok: token.VAR, Specs: []ast.Spec{spec}}})
+				}
+				*site.ref = &ast.UnaryExpr{OpPos: site.expr.Pos(), Op: token.AND, X: &ast.Ident{NamePos: site.expr.Pos(), Name: name}}
+				changed = true
+			}
+			result = append(result, stmt)
+		}
+		return result
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			n.List = stack(n.List)
+		case *ast.CaseClause:
+			n.Body = stack(n.Body)
+		case *ast.CommClause:
+			n.Body = stack(n.Body)
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// stackSite is an allocation that can be placed on the stack, and where it is in the statement
+type stackSite struct {
+	expr ast.Expr
+	ref  *ast.Expr
+}
+
+// stackSites finds the allocations in the given statement that do not escape, and that can be
+// moved out of the statement. The inner ones come first, since they are in the outer ones.
+func stackSites(stmt ast.Stmt, a *escapeAnalysis) []stackSite {
+	var whole ast.Expr // the value that is assigned, if there is only one
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if len(s.Rhs) == 1 && (s.Tok == token.ASSIGN || s.Tok == token.DEFINE) {
+			whole = s.Rhs[0]
+		}
+	case *ast.DeclStmt:
+		if d, ok := s.Decl.(*ast.GenDecl); ok && len(d.Specs) == 1 {
+			if vs, ok := d.Specs[0].(*ast.ValueSpec); ok && len(vs.Values) == 1 {
+				whole = vs.Values[0]
+			}
+		}
+	case *ast.ExprStmt:
+	default:
+		return nil
+	}
+	var sites []stackSite
+	var find func(n ast.Node)
+	find = func(n ast.Node) {
+		replaceRefs(n, func(ref *ast.Expr) {
+			e := *ref
+			if _, ok := e.(*ast.FuncLit); ok {
+				return
+			}
+			find(e)
+			if a.isSite(e) && a.escapes(escapeNode{site: e}) == "" && (e == whole || a.pure(e)) {
+				sites = append(sites, stackSite{e, ref})
+			}
+		})
+	}
+	find(stmt)
+	return sites
+}
+
+// replaceRefs calls f with a reference to each expression that is directly within the given node
+func replaceRefs(node ast.Node, f func(*ast.Expr)) {
+	switch n := node.(type) {
+	case *ast.AssignStmt:
+		for i := range n.Rhs {
+			f(&n.Rhs[i])
+		}
+		for i := range n.Lhs {
+			f(&n.Lhs[i])
+		}
+	case *ast.DeclStmt:
+		if d, ok := n.Decl.(*ast.GenDecl); ok {
+			for _, spec := range d.Specs {
+				if vs, ok := spec.(*ast.ValueSpec); ok {
+					for i := range vs.Values {
+						f(&vs.Values[i])
+					}
+				}
+			}
+		}
+	case *ast.ExprStmt:
+		f(&n.X)
+	case *ast.ParenExpr:
+		f(&n.X)
+	case *ast.UnaryExpr:
+		f(&n.X)
+	case *ast.StarExpr:
+		f(&n.X)
+	case *ast.BinaryExpr:
+		f(&n.X)
+		f(&n.Y)
+	case *ast.SelectorExpr:
+		f(&n.X)
+	case *ast.IndexExpr:
+		f(&n.X)
+		f(&n.Index)
+	case *ast.SliceExpr:
+		f(&n.X)
+	case *ast.CallExpr:
+		for i := range n.Args {
+			f(&n.Args[i])
+		}
+	case *ast.CompositeLit:
+		for i := range n.Elts {
+			f(&n.Elts[i])
+		}
+	case *ast.KeyValueExpr:
+		f(&n.Value)
+	}
+}
+
+// pure checks if evaluating the given expression can not have side effects or panic, so
+// that it can be evaluated before the statement that it is in
+func (a *escapeAnalysis) pure(e ast.Expr) bool {
+	result := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case nil, *ast.Ident, *ast.BasicLit, *ast.CompositeLit, *ast.KeyValueExpr, *ast.ParenExpr, *ast.ArrayType, *ast.MapType:
+		case *ast.UnaryExpr:
+			result = result && x.Op != token.ARROW
+		case *ast.BinaryExpr:
+			result = result && x.Op != token.QUO && x.Op != token.REM
+		case *ast.SelectorExpr:
+			result = result && !a.isPointer(x.X)
+		case *ast.CallExpr:
+			result = result && a.isSite(x)
+		default:
+			result = false
+		}
+		return result
+	})
+	return result
+}
+
+// ExplainEscapes writes where the values are allocated to stderr, and why they escape to
+// the heap, with the line and column of each allocation:
+//
+//	17:2: n escapes to heap: returned from counter
+//	23:9: &Node{...} escapes to heap: returned from push (through head)
+//	48:7: &Point{...} does not escape
+//
+// The allocations are &T{...}, new(T) and the local variables that have their address taken.
+func ExplainEscapes(source string) {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	a := analyzeEscapes(file, info, pkg)
+	type line struct {
+		pos     token.Pos
+		message string
+	}
+	var lines []line
+	explain := func(pos token.Pos, what string, n escapeNode, stackable bool) {
+		message := what + " does not escape"
+		if reason := a.escapes(n); reason != "" {
+			message = what + " escapes to heap: " + reason
+		} else if !stackable {
+			message += ", but is allocated on the heap, since it can not be moved out of its statement"
+		}
+		lines = append(lines, line{pos, message})
+	}
+	stackable := map[ast.Expr]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		}
+		for _, stmt := range list {
+			for _, site := range stackSites(stmt, a) {
+				stackable[site.expr] = true
+			}
+		}
+		return true
+	})
+	for _, site := range a.sites {
+		var what string
+		switch x := site.(type) {
+		case *ast.UnaryExpr:
+			what = "&" + exprString(x.X.(*ast.CompositeLit).Type) + "{...}"
+		case *ast.CallExpr:
+			what = "new(" + exprString(x.Args[0]) + ")"
+		}
+		explain(site.Pos(), what, escapeNode{site: site}, stackable[site])
+	}
+	for v := range a.addressed {
+		explain(v.Pos(), v.Name(), escapeNode{variable: v}, true)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].pos < lines[j].pos })
+	for _, l := range lines {
+		pos := fset.Position(l.pos)
+		fmt.Fprintf(os.Stderr, "%d:%d: %s\n", pos.Line, pos.Column, l.message)
+	}
+}
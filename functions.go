@@ -0,0 +1,243 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// deferredFunction is the parameter of the function literals that make the deferred calls of function values
+const deferredFunction = "_go_deferred"
+
+// literalVariablePrefix is the prefix of the variables that function literals are moved to
+const literalVariablePrefix = "_go_function"
+
+// FunctionVariables declares the variables that are given a function value with := with
+// their function type, so that they are _go_func values in C++, that other functions can be
+// assigned to, and not function pointers or lambdas, that each have a type of their own:
+//
+//	f := hello              ->  var f func(string) = hello
+//	g := func(x int) {...}  ->  var g func(int) = func(x int) {...}
+//
+// The deferred calls of function values, that are not declared functions or methods, are
+// made by a function literal that gets the function value and the arguments when the call
+// is deferred, since Go evaluates the function value then, and not when the call is made:
+//
+//	defer f("x")  ->  defer func(_go_deferred func(string), _go_arg0 string) { _go_deferred(_go_arg0) }(f, "x")
+//
+// The function literals on several lines that are the arguments of calls or the elements of
+// composite literals are moved to variables that are declared before the statement, since
+// TranslateLines only translates function literals on several lines that start a statement:
+//
+//	apply(func(x int) int {  ->  var _go_function0 func(int) int = func(x int) int {
+//	    return x * 2         ->      return x * 2
+//	}, 1)                    ->  }
+//	                         ->  apply(_go_function0, 1)
+func FunctionVariables(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	checkSource(fset, file, info)
+
+	changed := false
+	counter := 0
+	// moveLiterals moves the function literals on several lines in the given statement or
+	// declaration to variables, and returns the declarations of the variables
+	moveLiterals := func(node ast.Node, pos token.Pos) []*ast.GenDecl {
+		var literals []*ast.FuncLit
+		add := func(e ast.Expr) {
+			if lit, ok := e.(*ast.FuncLit); ok && fset.Position(lit.Pos()).Line != fset.Position(lit.End()).Line {
+				literals = append(literals, lit)
+			}
+		}
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.FuncLit:
+				// The function literals in the body are moved within the body
+				return false
+			case *ast.CallExpr:
+				for _, arg := range x.Args {
+					add(arg)
+				}
+			case *ast.CompositeLit:
+				for _, elt := range x.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						elt = kv.Value
+					}
+					add(elt)
+				}
+			}
+			return true
+		})
+		if len(literals) == 0 {
+			return nil
+		}
+		sort.Slice(literals, func(i, j int) bool { return literals[i].Pos() < literals[j].Pos() })
+		variables := map[*ast.FuncLit]*ast.Ident{}
+		var decls []*ast.GenDecl
+		for _, lit := range literals {
+			sig, ok := info.TypeOf(lit).(*types.Signature)
+			if !ok {
+				continue
+			}
+			id := &ast.Ident{NamePos: pos, Name: literalVariablePrefix + strconv.Itoa(counter)}
+			counter++
+			variables[lit] = &ast.Ident{NamePos: pos, Name: id.Name}
+			spec := &ast.ValueSpec{Names: []*ast.Ident{id}, Type: parseGenerated(funcTypeString(sig), pos), Values: []ast.Expr{lit}}
+			decls = append(decls, &ast.GenDecl{TokPos: pos, Tok: token.VAR, Specs: []ast.Spec{spec}})
+		}
+		replaceExprs(node, func(e ast.Expr) ast.Expr {
+			if lit, ok := e.(*ast.FuncLit); ok && variables[lit] != nil {
+				return variables[lit]
+			}
+			return e
+		})
+		// The rest of the statement or declaration is on one line, apart from the
+		// function literals in it that are not moved
+		ast.Inspect(node, func(n ast.Node) bool {
+			if _, ok := n.(*ast.FuncLit); ok || n == nil {
+				return false
+			}
+			movePositions(n, pos)
+			return true
+		})
+		changed = true
+		return decls
+	}
+	declare := func(list []ast.Stmt) []ast.Stmt {
+		var statements []ast.Stmt
+		for _, stmt := range list {
+			switch stmt.(type) {
+			case *ast.ExprStmt, *ast.AssignStmt, *ast.DeclStmt, *ast.ReturnStmt, *ast.SendStmt, *ast.GoStmt, *ast.DeferStmt:
+				for _, decl := range moveLiterals(stmt, stmt.Pos()) {
+					statements = append(statements, &ast.DeclStmt{Decl: decl})
+				}
+			}
+			statements = append(statements, stmt)
+		}
+		list = statements
+		for i, stmt := range list {
+			assign, ok := stmt.(*ast.AssignStmt)
+			if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+				continue
+			}
+			id, ok := assign.Lhs[0].(*ast.Ident)
+			if !ok || info.Defs[id] == nil {
+				continue
+			}
+			sig, ok := info.Defs[id].Type().(*types.Signature)
+			if !ok {
+				continue
+			}
+			spec := &ast.ValueSpec{Names: []*ast.Ident{id}, Type: parseGenerated(funcTypeString(sig), id.Pos()), Values: assign.Rhs}
+			list[i] = &ast.DeclStmt{Decl: &ast.GenDecl{TokPos: id.Pos(), Tok: token.VAR, Specs: []ast.Spec{spec}}}
+			changed = true
+		}
+		return list
+	}
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.VAR {
+			for _, moved := range moveLiterals(d, d.Pos()) {
+				decls = append(decls, moved)
+			}
+		}
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.BlockStmt:
+			x.List = declare(x.List)
+		case *ast.CaseClause:
+			x.Body = declare(x.Body)
+		case *ast.CommClause:
+			x.Body = declare(x.Body)
+		case *ast.DeferStmt:
+			if lit := deferredFunctionValue(x.Call, info); lit != nil {
+				x.Call = &ast.CallExpr{Fun: lit, Lparen: x.Call.Lparen, Args: append([]ast.Expr{x.Call.Fun}, x.Call.Args...), Rparen: x.Call.Rparen}
+				changed = true
+			}
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// deferredFunctionValue returns the function literal that makes the given deferred call of
+// a function value, or nil if the function is declared, or if the call can be deferred as it is
+func deferredFunctionValue(call *ast.CallExpr, info *types.Info) *ast.FuncLit {
+	if tv := info.Types[call.Fun]; tv.IsType() || tv.IsBuiltin() {
+		return nil
+	}
+	sig, ok := info.TypeOf(call.Fun).Underlying().(*types.Signature)
+	if !ok || declaredFunction(call.Fun, info) {
+		return nil
+	}
+	params := sig.Params()
+	if len(call.Args) == 1 && params.Len() > 1 {
+		// f(g()), with the results of g as the arguments
+		return nil
+	}
+	fields := []string{deferredFunction + " " + funcTypeString(sig)}
+	var args []string
+	for i := range call.Args {
+		var t types.Type
+		switch {
+		case !sig.Variadic() || i < params.Len()-1:
+			t = params.At(i).Type()
+		case call.Ellipsis.IsValid():
+			t = params.At(params.Len() - 1).Type()
+		default:
+			t = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		}
+		name := "_go_arg" + strconv.Itoa(i)
+		fields = append(fields, name+" "+goTypeString(t))
+		args = append(args, name)
+	}
+	ellipsis := ""
+	if call.Ellipsis.IsValid() {
+		ellipsis = "..."
+	}
+	lit := "func(" + strings.Join(fields, ", ") + ") { " + deferredFunction + "(" + strings.Join(args, ", ") + ellipsis + ") }"
+	return parseGenerated(lit, call.Pos()).(*ast.FuncLit)
+}
+
+// declaredFunction checks if the given expression is a declared function, a method, or a
+// function literal, and not a function value that is read from a variable or returned by a call
+func declaredFunction(e ast.Expr, info *types.Info) bool {
+	switch x := e.(type) {
+	case *ast.ParenExpr:
+		return declaredFunction(x.X, info)
+	case *ast.IndexExpr:
+		// A generic function with type arguments
+		return declaredFunction(x.X, info)
+	case *ast.IndexListExpr:
+		return declaredFunction(x.X, info)
+	case *ast.FuncLit:
+		return true
+	case *ast.Ident:
+		_, ok := info.Uses[x].(*types.Func)
+		return ok
+	case *ast.SelectorExpr:
+		if selection := info.Selections[x]; selection != nil {
+			// Methods takes the receiver of a method call as the first argument, which
+			// is then evaluated when the call is deferred, like the other arguments
+			return selection.Kind() == types.MethodVal
+		}
+		_, ok := info.Uses[x.Sel].(*types.Func)
+		return ok
+	}
+	return false
+}
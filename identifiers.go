@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/types"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// reservedNames are the names that Go identifiers can have, but that can not be used as
+// identifiers in the generated C++ code: the C++ keywords, the macros, and the names that
+// the C and C++ headers declare outside of the std namespace
+var reservedNames = map[string]bool{
+	// C++ keywords, and the alternative tokens
+	"alignas": true, "alignof": true, "and": true, "and_eq": true, "asm": true, "auto": true,
+	"bitand": true, "bitor": true, "bool": true, "catch": true, "char": true, "char8_t": true,
+	"char16_t": true, "char32_t": true, "class": true, "compl": true, "concept": true,
+	"consteval": true, "constexpr": true, "constinit": true, "const_cast": true,
+	"co_await": true, "co_return": true, "co_yield": true, "decltype": true, "delete": true,
+	"do": true, "double": true, "dynamic_cast": true, "enum": true, "explicit": true,
+	"export": true, "extern": true, "false": true, "float": true, "friend": true,
+	"inline": true, "int": true, "long": true, "mutable": true, "namespace": true, "new": true,
+	"noexcept": true, "not": true, "not_eq": true, "nullptr": true, "operator": true, "or": true,
+	"or_eq": true, "private": true, "protected": true, "public": true, "register": true,
+	"reinterpret_cast": true, "requires": true, "short": true, "signed": true, "sizeof": true,
+	"static": true, "static_assert": true, "static_cast": true, "template": true, "this": true,
+	"thread_local": true, "throw": true, "true": true, "try": true, "typedef": true,
+	"typeid": true, "typename": true, "union": true, "unsigned": true, "using": true,
+	"virtual": true, "void": true, "volatile": true, "wchar_t": true, "while": true,
+	"xor": true, "xor_eq": true,
+	// Macros
+	"NULL": true, "EOF": true, "assert": true, "errno": true, "stdin": true, "stdout": true,
+	"stderr": true, "offsetof": true, "INFINITY": true, "NAN": true, "HUGE_VAL": true,
+	"INT_MAX": true, "INT_MIN": true, "UINT_MAX": true, "LLONG_MAX": true, "LLONG_MIN": true,
+	"CHAR_BIT": true, "RAND_MAX": true, "EXIT_SUCCESS": true, "EXIT_FAILURE": true,
+	"BUFSIZ": true, "M_PI": true, "M_E": true,
+	// Names outside of the std namespace
+	"std": true, "main": true, "size_t": true, "ptrdiff_t": true, "int8_t": true,
+	"int16_t": true, "int32_t": true, "int64_t": true, "uint8_t": true, "uint16_t": true,
+	"uint32_t": true, "uint64_t": true, "printf": true, "sprintf": true, "snprintf": true,
+	"fprintf": true, "scanf": true, "puts": true, "putchar": true, "getchar": true,
+	"exit": true, "abort": true, "malloc": true, "calloc": true, "realloc": true, "free": true,
+	"system": true, "getenv": true, "abs": true, "labs": true, "div": true, "rand": true,
+	"srand": true, "atoi": true, "atof": true, "strlen": true, "strcmp": true, "memcpy": true,
+	"memset": true, "sqrt": true, "cbrt": true, "pow": true, "exp": true, "log": true,
+	"log2": true, "log10": true, "sin": true, "cos": true, "tan": true, "asin": true,
+	"acos": true, "atan": true, "atan2": true, "sinh": true, "cosh": true, "tanh": true,
+	"floor": true, "ceil": true, "round": true, "trunc": true, "fmod": true, "fabs": true,
+	"hypot": true, "isnan": true, "isinf": true, "time": true, "clock": true, "remove": true,
+	"rename": true, "signal": true, "raise": true, "y0": true, "y1": true, "j0": true, "j1": true,
+	// Names in the generated code, that are not functions
+	"error": true,
+}
+
+// goNames are the Go names of the identifiers that Identifiers has renamed, by their C++ names
+var goNames = map[string]string{}
+
+// needsRenaming checks if the given Go identifier can not be used as it is in C++, since it is
+// reserved, or the name of a function that go2cpp generates. The names that start with "_" or
+// contain "__" are all renamed, since go2cpp uses such names for what it generates, like
+// _go_new, and the _s__, _l__ and _d__ prefixes, and since C++ reserves some of them.
+func needsRenaming(name string) bool {
+	if name == "_" {
+		return false
+	}
+	if reservedNames[name] || strings.HasPrefix(name, "_") || strings.Contains(name, "__") {
+		return true
+	}
+	// The functions in the generated code, like len, and the ones that replace the
+	// functions in the Go standard library, like fmtSprintf for fmt.Sprintf
+	for _, function := range functionOrder {
+		if strings.Replace(function, ".", "", -1) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Identifiers renames the identifiers that are declared in the main package, and that can not
+// be used as they are in C++, by adding the identifierPrefix:
+//
+//	new := 1               ->  _i__new := 1
+//	var class, this int    ->  var _i__class, _i__this int
+//	func printf() {        ->  func _i__printf() {
+//	_s__x := 2             ->  _i___s__x := 2
+//
+// The renamed identifiers are recorded in goNames, and removing the prefix gives the Go name.
+// This is the first rewrite, so that the other rewrites can add names that start with "_".
+func Identifiers(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Defs:      map[*ast.Ident]types.Object{},
+		Uses:      map[*ast.Ident]types.Object{},
+		Implicits: map[ast.Node]types.Object{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	// The objects in the main package that are renamed
+	renamed := map[types.Object]bool{}
+	for _, obj := range info.Defs {
+		if obj != nil && obj.Pkg() == pkg && !isPackageName(obj) && needsRenaming(obj.Name()) {
+			renamed[obj] = true
+		}
+	}
+	for _, obj := range info.Implicits {
+		if obj.Pkg() == pkg && needsRenaming(obj.Name()) {
+			renamed[obj] = true
+		}
+	}
+	if len(renamed) == 0 {
+		return source
+	}
+	// The main function is called by the generated code
+	if obj := pkg.Scope().Lookup("main"); obj != nil {
+		delete(renamed, obj)
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Ident:
+			obj := info.Defs[x]
+			if obj == nil {
+				obj = info.Uses[x]
+			}
+			if renamed[obj] {
+				rename(x)
+			}
+		case *ast.TypeSwitchStmt:
+			// The name in "switch v := x.(type)" has an object for each case clause
+			if assign, ok := x.Assign.(*ast.AssignStmt); ok && len(assign.Lhs) == 1 {
+				if id, ok := assign.Lhs[0].(*ast.Ident); ok && needsRenaming(id.Name) {
+					rename(id)
+				}
+			}
+		}
+		return true
+	})
+
+	return formatFile(fset, file)
+}
+
+// rename adds the identifierPrefix to the given identifier, and records its Go name
+func rename(id *ast.Ident) {
+	goNames[identifierPrefix+id.Name] = id.Name
+	id.Name = identifierPrefix + id.Name
+}
+
+// isPackageName checks if the given object is the name of an imported package
+func isPackageName(obj types.Object) bool {
+	_, ok := obj.(*types.PkgName)
+	return ok
+}
+
+// GoNames replaces the identifiers in the given C++ code, or in the messages about it, that
+// Identifiers has renamed, with their Go names
+func GoNames(s string) string {
+	var names []string
+	for name := range goNames {
+		names = append(names, name)
+	}
+	// The longest names first, since a name may start with another name
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		s = strings.Replace(s, name, goNames[name], -1)
+	}
+	return s
+}
+
+// WriteSourceMap writes the Go names of the identifiers that Identifiers has renamed, by
+// their C++ names, to the given file, as JSON
+func WriteSourceMap(filename string) error {
+	data, err := json.MarshalIndent(map[string]interface{}{"names": goNames}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+const initPrefix = "_go_init_"
+
+// Initialization orders the package level variables like Go initializes them, and makes main
+// call the init functions before anything else, in the order that they are declared in:
+//
+//	var total = sum(values)    ->  var b = 2
+//	var values = []int{a, b}   ->  var a = b + 1
+//	var a = b + 1              ->  var values = []int{a, b}
+//	var b = 2                  ->  var total = sum(values)
+//	                           ->
+//	func init() {              ->  func _go_init_0() {
+//	    ...                    ->      ...
+//	}                          ->  }
+//	                           ->
+//	func main() {              ->  func main() {
+//	    ...                    ->      _go_init_0()
+//	}                          ->      ...
+//	                           ->  }
+//
+// A variable is initialized after the variables that its initializer depends on, also through
+// the functions that it calls, which go/types has found. The variables without an initializer
+// are declared first, since they are zero values. The C++ globals are then initialized in the
+// same order, since they are initialized in the order that they are declared in.
+// The imported packages are translated to the C++ standard library, which needs no
+// initialization, so only the variables and init functions in the main package are ordered.
+func Initialization(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{Defs: map[*ast.Ident]types.Object{}}
+	checkSource(fset, file, info)
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+
+	// The variable declarations, and the source code of each of them
+	var varDecls []*ast.GenDecl
+	var specs, zeroSpecs []*ast.ValueSpec
+	specOf := map[types.Object]*ast.ValueSpec{}
+	specSource := map[*ast.ValueSpec]string{}
+	var inits []*ast.FuncDecl
+	var mainFunc *ast.FuncDecl
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.VAR {
+				continue
+			}
+			varDecls = append(varDecls, d)
+			for _, spec := range d.Specs {
+				vs := spec.(*ast.ValueSpec)
+				doc := vs.Doc
+				if !d.Lparen.IsValid() {
+					doc = d.Doc
+				}
+				text := "var " + source[offset(vs.Pos()):offset(vs.End())]
+				if vs.Comment != nil {
+					text = "var " + source[offset(vs.Pos()):offset(vs.Comment.End())]
+				}
+				if doc != nil {
+					text = source[offset(doc.Pos()):offset(doc.End())] + "\n" + text
+				}
+				specs = append(specs, vs)
+				specSource[vs] = text
+				if len(vs.Values) == 0 {
+					zeroSpecs = append(zeroSpecs, vs)
+					continue
+				}
+				for _, name := range vs.Names {
+					if obj := info.Defs[name]; obj != nil {
+						specOf[obj] = vs
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Body != nil && d.Name.Name == "init" {
+				inits = append(inits, d)
+			} else if d.Recv == nil && d.Body != nil && d.Name.Name == "main" {
+				mainFunc = d
+			}
+		}
+	}
+
+	// The declarations with initializers, in the order that Go initializes them in,
+	// and then the ones that go/types could not order
+	ordered := zeroSpecs
+	written := map[*ast.ValueSpec]bool{}
+	for _, initializer := range info.InitOrder {
+		for _, v := range initializer.Lhs {
+			if vs, ok := specOf[v]; ok && !written[vs] {
+				written[vs] = true
+				ordered = append(ordered, vs)
+			}
+		}
+	}
+	for _, vs := range specs {
+		if len(vs.Values) > 0 && !written[vs] {
+			ordered = append(ordered, vs)
+		}
+	}
+	inOrder := true
+	var texts []string
+	for i, vs := range ordered {
+		inOrder = inOrder && vs == specs[i]
+		texts = append(texts, specSource[vs])
+	}
+
+	// The declarations are written where the first one is
+	reordered := source
+	if !inOrder {
+		var sb strings.Builder
+		pos := 0
+		for i, d := range varDecls {
+			start := offset(d.Pos())
+			if d.Doc != nil {
+				start = offset(d.Doc.Pos())
+			}
+			sb.WriteString(source[pos:start])
+			if i == 0 {
+				sb.WriteString(strings.Join(texts, "\n\n"))
+			}
+			pos = offset(d.End())
+		}
+		sb.WriteString(source[pos:])
+		reordered = formatSource(sb.String())
+	}
+	if len(inits) == 0 || mainFunc == nil {
+		return reordered
+	}
+
+	// The init functions are renamed, and called at the start of main
+	fset, file = parseSource(reordered)
+	var calls []ast.Stmt
+	for _, decl := range file.Decls {
+		f, ok := decl.(*ast.FuncDecl)
+		if !ok || f.Recv != nil || f.Body == nil {
+			continue
+		}
+		if f.Name.Name == "init" {
+			f.Name.Name = initPrefix + strconv.Itoa(len(calls))
+			calls = append(calls, &ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent(f.Name.Name)}})
+		}
+	}
+	for _, decl := range file.Decls {
+		if f, ok := decl.(*ast.FuncDecl); ok && f.Recv == nil && f.Body != nil && f.Name.Name == "main" {
+			for _, c := range calls {
+				c.(*ast.ExprStmt).X.(*ast.CallExpr).Fun.(*ast.Ident).NamePos = f.Body.Lbrace
+			}
+			f.Body.List = append(calls, f.Body.List...)
+		}
+	}
+	return formatFile(fset, file)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// Integers rewrites the integer operations that are not the same in Go and C++,
+// so that the results are the same as in Go on amd64:
+//
+//	x := 5      ->  x := int(5)
+//	a &^ b      ->  a & _go_complement(b)
+//	^a          ->  _go_complement(a)
+//	a << n      ->  _go_shift_left(a, n)
+//	a >> n      ->  _go_shift_right(a, n)
+//	b + c       ->  uint8(b + c)
+//
+// The variables that are declared with an untyped integer constant get the type int,
+// which is 64 bits, and not the type of the literal in C++. Shifts by a count that is
+// not a constant less than the width of the type are done by functions that give 0 when
+// everything is shifted out. The arithmetic on 8 and 16 bit integers is converted back
+// to the type, since C++ does it with int. Signed integers wrap around, since the C++
+// code is compiled with -fwrapv.
+func Integers(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	checkSource(fset, file, info)
+	sizes := types.SizesFor("gc", "amd64")
+
+	changed := false
+	// The expressions that have been replaced, and the expressions that they replaced
+	originals := make(map[ast.Expr]ast.Expr)
+	typeAndValue := func(e ast.Expr) types.TypeAndValue {
+		if original, ok := originals[e]; ok {
+			e = original
+		}
+		return info.Types[e]
+	}
+	// integer returns the integer type of the given expression, or nil
+	integer := func(e ast.Expr) *types.Basic {
+		tv := typeAndValue(e)
+		if tv.Type == nil {
+			return nil
+		}
+		if b, ok := tv.Type.Underlying().(*types.Basic); ok && b.Info()&types.IsInteger != 0 && b.Info()&types.IsUntyped == 0 {
+			return b
+		}
+		return nil
+	}
+	convert := func(e ast.Expr, t *types.Basic) ast.Expr {
+		return call(t.Name(), e, e)
+	}
+	// shiftCall returns the function that shifts by the given count, or "" if the count is
+	// a constant that is less than the width of the type, so that C++ shifts like Go does
+	shiftCall := func(op token.Token, n ast.Expr, t *types.Basic) string {
+		if tv := typeAndValue(n); tv.Value != nil {
+			if count, exact := constant.Int64Val(constant.ToInt(tv.Value)); exact && count >= 0 && count < 8*sizes.Sizeof(t) {
+				return ""
+			}
+		}
+		if op == token.SHL || op == token.SHL_ASSIGN {
+			return "_go_shift_left"
+		}
+		return "_go_shift_right"
+	}
+
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		if typeAndValue(e).Value != nil {
+			return e
+		}
+		t := integer(e)
+		if t == nil {
+			return e
+		}
+		result := e
+		switch x := e.(type) {
+		case *ast.BinaryExpr:
+			switch x.Op {
+			case token.AND_NOT:
+				result = &ast.BinaryExpr{X: x.X, OpPos: x.OpPos, Op: token.AND, Y: call("_go_complement", x.Y, x.Y)}
+			case token.SHL, token.SHR:
+				if function := shiftCall(x.Op, x.Y, t); function != "" {
+					left := x.X
+					if typeAndValue(left).Value != nil {
+						// An untyped constant that is shifted gets the type from the context
+						left = convert(left, t)
+					}
+					result = call(function, e, left, x.Y)
+				}
+			}
+		case *ast.UnaryExpr:
+			if x.Op == token.XOR {
+				result = call("_go_complement", e, x.X)
+			}
+		}
+		if _, ok := result.(*ast.CallExpr); !ok && sizes.Sizeof(t) < 4 {
+			switch result.(type) {
+			case *ast.BinaryExpr, *ast.UnaryExpr:
+				result = convert(result, t)
+			}
+		}
+		if result != e {
+			changed = true
+			originals[result] = e
+		}
+		return result
+	})
+
+	// defaultInt converts the untyped integer constants that are given to new variables to int,
+	// and the untyped rune constants to rune
+	defaultInt := func(values []ast.Expr) {
+		for i, value := range values {
+			tv := typeAndValue(value)
+			if tv.Value == nil || (tv.Type != types.Typ[types.Int] && tv.Type != types.Typ[types.Int32]) {
+				continue
+			}
+			if c, ok := value.(*ast.CallExpr); ok && len(c.Args) == 1 {
+				if id, ok := c.Fun.(*ast.Ident); ok && (id.Name == "int" || id.Name == "rune" || id.Name == "int32") {
+					continue
+				}
+			}
+			values[i] = convert(value, tv.Type.(*types.Basic))
+			changed = true
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			// The constants are declared with their values
+			return n.Tok != token.CONST
+		case *ast.AssignStmt:
+			switch n.Tok {
+			case token.DEFINE:
+				defaultInt(n.Rhs)
+			case token.AND_NOT_ASSIGN:
+				n.Tok = token.AND_ASSIGN
+				n.Rhs[0] = call("_go_complement", n.Rhs[0], n.Rhs[0])
+				changed = true
+			case token.SHL_ASSIGN, token.SHR_ASSIGN:
+				t := integer(n.Lhs[0])
+				if t == nil {
+					break
+				}
+				if function := shiftCall(n.Tok, n.Rhs[0], t); function != "" {
+					n.Tok = token.ASSIGN
+					n.Rhs[0] = call(function, n.Rhs[0], copyExpr(n.Lhs[0]), n.Rhs[0])
+					changed = true
+				}
+			}
+		case *ast.ValueSpec:
+			if n.Type == nil {
+				defaultInt(n.Values)
+			}
+		case *ast.CallExpr:
+			// The constants that are given as int or interface values, so that the arguments have the
+			// types of the parameters in C++, and functions like fmt.Printf get the types of the values
+			tv := typeAndValue(n.Fun)
+			sig, ok := tv.Type.(*types.Signature)
+			if !ok || tv.IsBuiltin() {
+				break
+			}
+			for i := range n.Args {
+				var param types.Type
+				if sig.Variadic() && i >= sig.Params().Len()-1 {
+					param = sig.Params().At(sig.Params().Len() - 1).Type()
+					if s, ok := param.(*types.Slice); ok && !n.Ellipsis.IsValid() {
+						param = s.Elem()
+					}
+				} else if i < sig.Params().Len() {
+					param = sig.Params().At(i).Type()
+				}
+				if param != nil && (types.IsInterface(param) || param == types.Typ[types.Int]) {
+					defaultInt(n.Args[i : i+1])
+				}
+			}
+		case *ast.RangeStmt:
+			values := []ast.Expr{n.X}
+			defaultInt(values)
+			n.X = values[0]
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Literals rewrites the literals to forms that mean the same in Go and C++:
+//
+//	`a\b`      ->  "a\\b"
+//	"\x41B"    ->  "AB"
+//	"\xff"     ->  "\377"
+//	"a/b"      ->  "a\057b"
+//	'世'       ->  19990
+//	'\n'       ->  10
+//	0b1010     ->  10
+//	0o17       ->  15
+//	1_000_000  ->  1000000
+//	0x1p-2     ->  0.25
+//
+// Strings are given with the escape sequences that C++ reads in the same way, which are
+// octal escapes with three digits for the bytes that are not printable, and for slashes.
+// Raw strings become interpreted strings, so that they can contain anything, also over
+// several lines.
+// Rune literals are given as their values, unless they are printable ASCII characters.
+// The imaginary literals are left as they are.
+func Literals(source string) string {
+	fset, file := parseSource(source)
+	changed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if _, ok := n.(*ast.ImportSpec); ok {
+			// The import paths are read by the translation as they are
+			return false
+		}
+		lit, ok := n.(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		value := literal(lit)
+		if value != lit.Value {
+			lit.Value = value
+			changed = true
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// literal returns the given literal in a form that means the same in Go and C++
+func literal(lit *ast.BasicLit) string {
+	switch lit.Kind {
+	case token.STRING:
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return lit.Value
+		}
+		return quoteString(s)
+	case token.CHAR:
+		value := constant.MakeFromLiteral(lit.Value, lit.Kind, 0)
+		r, ok := constant.Int64Val(value)
+		if !ok {
+			return lit.Value
+		}
+		if r >= ' ' && r < utf8.RuneSelf && r != '\'' && r != '\\' {
+			return "'" + string(rune(r)) + "'"
+		}
+		return strconv.FormatInt(r, 10)
+	case token.INT:
+		prefix := strings.ToLower(lit.Value)
+		if len(prefix) > 2 {
+			prefix = prefix[:2]
+		}
+		if !strings.Contains(lit.Value, "_") && prefix != "0b" && prefix != "0o" {
+			// Decimal, hexadecimal and octal literals with a leading 0 are the same in C++
+			return lit.Value
+		}
+		value := constant.MakeFromLiteral(lit.Value, lit.Kind, 0)
+		if i, ok := constant.Int64Val(value); ok {
+			return strconv.FormatInt(i, 10)
+		}
+		if u, ok := constant.Uint64Val(value); ok {
+			// Decimal literals that are too large for a signed integer are not unsigned in C++
+			return "0x" + strconv.FormatUint(u, 16)
+		}
+	case token.FLOAT:
+		if !strings.ContainsAny(lit.Value, "_xX") {
+			return lit.Value
+		}
+		value := constant.MakeFromLiteral(lit.Value, lit.Kind, 0)
+		if f, _ := constant.Float64Val(value); !math.IsInf(f, 0) {
+			s := strconv.FormatFloat(f, 'g', -1, 64)
+			if !strings.ContainsAny(s, ".e") {
+				s += ".0"
+			}
+			return s
+		}
+	}
+	return lit.Value
+}
+
+// quoteString returns the given string as a string literal with the same bytes in Go and C++
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"':
+			sb.WriteString(`\"`)
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '/':
+			// The translation takes // to start a comment, also within string literals
+			sb.WriteString(`\057`)
+		case r == utf8.RuneError && size == 1, r < ' ', r == 0x7f, r >= utf8.RuneSelf && !unicode.IsPrint(r):
+			// The hexadecimal escapes in C++ do not end after two digits, but the octal escapes end after three
+			for _, b := range []byte(s[i : i+size]) {
+				fmt.Fprintf(&sb, `\%03o`, b)
+			}
+		default:
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -23,12 +23,31 @@ const versionString = "xyproto/go2cpp 0.4.0"
 
 const tupleType = "std::tuple"
 
+// mapIterationOrder is the order in which the generated code iterates over maps:
+// "unordered" (the order of std::unordered_map), "random" (a random starting
+// point for each range loop, like Go) or "insertion" (deterministic).
+var mapIterationOrder = "unordered"
+
+// runtimeChecks is if the generated code checks for the runtime errors that
+// Go panics on, like indexing out of range and integer division by zero
+var runtimeChecks = true
+
+// memoryManagement is how the generated code frees the values that pointers point to:
+// "refcount" (reference counting, which does not free cycles) or "gc" (a tracing collector)
+var memoryManagement = "refcount"
+
+// explainEscapes is if go2cpp writes the allocations to stderr, and why they escape to the heap
+var explainEscapes = false
+
 const (
 	hashMapSuffix = "_h__"
 	keysSuffix    = "_k__"
 	switchPrefix  = "_s__"
 	labelPrefix   = "_l__"
 	deferPrefix   = "_d__"
+	// identifierPrefix is added to the Go identifiers that can not be used as they are in C++
+	identifierPrefix = "_i__"
+	deferStack       = deferPrefix + "stack"
 )
 
 var includeMap = map[string]string{
@@ -48,12 +67,19 @@ var includeMap = map[string]string{
 	"std::uint16_t":                    "cinttypes",
 	"std::uint32_t":                    "cinttypes",
 	"std::uint64_t":                    "cinttypes",
+	"std::uintptr_t":                   "cinttypes",
+	"std::numeric_limits":              "limits",
 	"printf":                           "cstdio",
 	"fprintf":                          "cstdio",
 	"sprintf":                          "cstdio",
 	"snprintf":                         "cstdio",
 	"std::stringstream":                "sstream",
+	"std::quoted":                      "iomanip",
+	"std::string_view":                 "string_view",
 	"std::is_pointer":                  "type_traits",
+	"std::is_integral_v":               "type_traits",
+	"std::is_floating_point_v":         "type_traits",
+	"std::is_unsigned_v":               "type_traits",
 	"std::experimental::is_detected_v": "experimental/type_traits",
 	"std::shared_ptr":                  "memory",
 	"std::nullopt":                     "optional",
@@ -65,19 +91,55 @@ var includeMap = map[string]string{
 	"std::regex_replace":               "regex",
 	"std::regex_constants":             "regex",
 	"std::to_string":                   "string",
-	// TODO: complex64, complex128
+	"std::tie":                         "tuple",
+	"std::pair":                        "utility",
+	"std::initializer_list":            "initializer_list",
+	"std::sort":                        "algorithm",
+	"std::remove_cvref_t":              "type_traits",
+	"std::nullptr_t":                   "cstddef",
+	"std::array":                       "array",
+	"std::mt19937_64":                  "random",
+	"std::any":                         "any",
+	"std::function":                    "functional",
+	"std::exchange":                    "utility",
+	"std::cerr":                        "iostream",
+	"std::rotate":                      "algorithm",
+	"std::copy":                        "algorithm",
+	"typeid":                           "typeinfo",
+	"std::deque":                       "deque",
+	"std::optional":                    "optional",
+	"std::exit":                        "cstdlib",
+	"std::complex":                     "complex",
+	"std::signbit":                     "cmath",
+	"std::isnan":                       "cmath",
+	"std::to_chars":                    "charconv",
+	"std::map":                         "map",
+	"std::unordered_set":               "unordered_set",
+	"std::max":                         "algorithm",
 }
 
 var endings = []string{"{", ",", "}", ":"}
 
+// assignmentOperators are the operators that can be combined with "=",
+// the operators that are two letters long are listed first
+var assignmentOperators = []string{"<<", ">>", "&^", "+", "-", "*", "/", "%", "&", "|", "^"}
+
+var (
+	labelRegexp          = regexp.MustCompile(`^(\w+):$`)
+	rangeLoopRegexp      = regexp.MustCompile(`^([\w\s,]+?)\s*(:=|=)\s*range\s+(.+)$`)
+	mapValueTypeRegexp   = regexp.MustCompile(`^[\w\.\*\[\]]+$`)
+	boxDeclarationRegexp = regexp.MustCompile(`^\w+ := _go_box\(`)
+	arrayLiteralRegexp   = regexp.MustCompile(`^\[(\d+|\.\.\.|\w+|)\]([\w\.\*\[\]]+|func\([^{]*\)[^{]*)\{`)
+)
+
 var (
 	switchExpressionCounter = -1
-	firstCase               bool
+	breakables              []*breakable // the for loops and switches that the current line is within
 	switchLabel             string
 	labelCounter            int
-	iotaNumber              int // used for simple increases of iota constants
 	deferCounter            int
 	unfinishedDeferFunction bool
+	structFieldTypes        = map[string][]string{} // the Go types of the fields of the encountered structs
 )
 
 // between returns the string between two given strings, or the original string
@@ -126,54 +188,49 @@ func greedyBetween(s, a, b string) string {
 	return between(s, a, b, false, true)
 }
 
-// TODO: Make more robust, this easily breaks
+// LiteralStrings gives the string literals that contain NUL bytes as std::string values
+// with an explicit length, since C++ ends the other string literals at the first NUL:
+//
+//	"a\000b"  ->  std::string("a\000b", 3)
 func LiteralStrings(source string) string {
-
-	// Temporary
-	return source
-
-	//output := source
-	//replacements := map[string]string{
-	//	"\")":  "\"s)",
-	//	"\";":  "\"s;",
-	//	"\",":  "\"s,",
-	//	"\"}":  "\"s}",
-	//	"\" }": "\"s }",
-	//	"\" )": "\"s )",
-	//	"\":":  "\"s:",
-	//}
-	//hasLiteral := false
-	//for k, v := range replacements {
-	//	if strings.Contains(output, k) {
-	//		output = strings.Replace(output, k, v, -1)
-	//		hasLiteral = true
-	//	}
-	//}
-	//if hasLiteral {
-	//	output = "\nusing namespace std::string_literals;\n" + output
-	//}
-	//return output
-}
-
-func initializeVariables(sourceCode string) string {
-	varTypes := []string{
-		"int", "double", "float", "char \\*", "const char \\*",
-		"std::string", "uint64_t", "uint32_t", "uint16_t", "uint8_t",
-		"int64_t", "int32_t", "int16_t", "int8_t", "unsigned int",
-	}
-
-	for _, varType := range varTypes {
-		re := regexp.MustCompile(varType + ` ([a-zA-Z_][a-zA-Z0-9_]*)\s*;`)
-		switch varType {
-		case "int", "float", "double", "uint64_t", "uint32_t", "uint16_t", "uint8_t", "int64_t", "int32_t", "int16_t", "int8_t", "unsigned int":
-			sourceCode = re.ReplaceAllString(sourceCode, varType+" $1 = 0;")
-		case "char \\*", "const char \\*":
-			sourceCode = re.ReplaceAllString(sourceCode, varType+" $1 = nullptr;")
-		case "std::string":
-			sourceCode = re.ReplaceAllString(sourceCode, varType+" $1 = \"\";")
-		}
-	}
-	return sourceCode
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, `\000`) {
+			continue
+		}
+		var sb strings.Builder
+		for pos := 0; pos < len(line); pos++ {
+			c := line[pos]
+			if c == '/' && strings.HasPrefix(line[pos:], "//") {
+				// The slashes in string literals are escaped, so this is a comment
+				sb.WriteString(line[pos:])
+				break
+			}
+			if c != '"' && c != '\'' {
+				sb.WriteByte(c)
+				continue
+			}
+			end := pos + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				sb.WriteString(line[pos:])
+				break
+			}
+			literal := line[pos : end+1]
+			if s, err := strconv.Unquote(literal); c == '"' && err == nil && strings.Contains(s, "\x00") {
+				literal = fmt.Sprintf("std::string(%s, %d)", literal, len(s))
+			}
+			sb.WriteString(literal)
+			pos = end
+		}
+		lines[i] = sb.String()
+	}
+	return strings.Join(lines, "\n")
 }
 
 // TODO: Avoid whole-program replacements, if possible
@@ -181,69 +238,178 @@ func WholeProgramReplace(source string) (output string) {
 	output = source
 	// TODO: Add these in a smarter way, with more supported types
 	replacements := map[string]string{
-		" string ":         " " + TypeReplace("string") + " ",
-		"(string ":         "(" + TypeReplace("string") + " ",
-		"return string":    "return std::to_string",
-		"make([]string, ":  "std::vector<" + TypeReplace("string") + "> (",
-		"make([]int, ":     "std::vector<" + TypeReplace("int") + "> (",
-		"make([]uint, ":    "std::vector<" + TypeReplace("uint") + "> (",
-		"make([]float64, ": "std::vector<" + TypeReplace("float64") + "> (",
-		"make([]float32, ": "std::vector<" + TypeReplace("float32") + "> (",
-		"-> []string":      "-> std::vector<" + TypeReplace("string") + ">",
-		"-> []int":         "-> std::vector<" + TypeReplace("int") + ">",
-		"-> []uint":        "-> std::vector<" + TypeReplace("uint") + ">",
-		"-> []float64":     "-> std::vector<" + TypeReplace("float64") + ">",
-		"-> []float32":     "-> std::vector<" + TypeReplace("float32") + ">",
-		"= nil)":           "= std::nullopt)",
+		" string ":      " " + TypeReplace("string") + " ",
+		"(string ":      "(" + TypeReplace("string") + " ",
+		"return string": "return std::to_string",
+		"= nil)":        "= std::nullopt)",
 	}
 	for k, v := range replacements {
 		output = strings.Replace(output, k, v, -1)
 	}
-	return initializeVariables(output)
+	return output
 }
 
-func AddFunctions(source string, useFormatOutput, haveStructs bool) (output string) {
+// functionOrder is the order in which the functions from AddFunctions are
+// placed in the generated C++ code. Functions may only use functions that
+// come before them in this list.
+var functionOrder = []string{
+	"_go_error",
+	"errors.New",
+	"strconv.ParseFloat",
+	"strconv.ParseInt",
+	"strings.Contains",
+	"strings.HasPrefix",
+	"strings.TrimSpace",
+	"fmt.Sprintf",
+	"len",
+	"_format_output",
+	"_go_any",
+	"_go_struct",
+	"_go_panic",
+	"_go_convert",
+	"_go_complex",
+	"cmplx.Abs",
+	"cmplx.Phase",
+	"cmplx.Exp",
+	"cmplx.Sqrt",
+	"cmplx.Polar",
+	"cmplx.Rect",
+	"cmplx.Conj",
+	"_go_div",
+	"_go_mod",
+	"_go_shift",
+	"_go_complement",
+	"_go_ptr",
+	"_go_new",
+	"_go_address",
+	"_go_deref",
+	"_go_func",
+	"_go_chan",
+	"_go_box",
+	"_go_assert",
+	"_go_index",
+	"_go_slice",
+	"strings.Split",
+	"_go_slicing",
+	"_go_sprint",
+	"_go_defer",
+	"_go_range",
+	"_go_ref",
+	"_go_hash",
+	"_go_map",
+}
+
+func AddFunctions(source string) (output string) {
 
 	// TODO: Make the fmtSprintf implementation more watertight. Use variadic templates and parameter packs, while waiting for std::format to arrive in the C++20 implementations.
 
 	output = source
 	replacements := map[string]string{
-		"strconv.ParseFloat": `using error = std::optional<std::string>;
-auto strconvParseFloat(std::string s, int bitSize) -> std::tuple<double, error> {
+		"_go_error": `
+// _go_error is the error interface, for the errors that are made with errors.New. A zero
+// _go_error is nil, and Println prints the message of an error like Go does.
+struct _go_error {
+    std::optional<std::string> _message;
+
+    _go_error() = default;
+    _go_error(std::nullptr_t) {}
+    explicit _go_error(std::string message)
+        : _message { std::move(message) }
+    {
+    }
+    auto Error() const -> std::string { return _message.value_or("<nil>"); }
+    auto _str() const -> std::string { return Error(); }
+    friend auto operator==(const _go_error& e, std::nullptr_t) -> bool { return !e._message; }
+};
+`,
+		"errors.New": `inline auto errorsNew(std::string message) -> _go_error { return _go_error { std::move(message) }; }`,
+		"strconv.ParseFloat": `auto strconvParseFloat(std::string s, int bitSize) -> std::tuple<double, _go_error> {
 	try {
-		return std::tuple { std::stod(s), std::nullopt };
+		return std::tuple { std::stod(s), _go_error {} };
 	} catch (const std::invalid_argument& ia) {
-		return std::tuple { 0.0, std::optional { "invalid argument" } };
+		return std::tuple { 0.0, _go_error { "invalid argument" } };
 	}
 }
 `,
-		"strconv.ParseInt": `using error = std::optional<std::string>;
-auto strconvParseInt(std::string s, int base, int bitSize) -> std::tuple<int, error> {
+		"strconv.ParseInt": `auto strconvParseInt(std::string s, int base, int bitSize) -> std::tuple<std::int64_t, _go_error> {
 	try {
-		return std::tuple { std::stoi(s), std::nullopt };
+		return std::tuple<std::int64_t, _go_error> { std::stoll(s, nullptr, base), _go_error {} };
 	} catch (const std::invalid_argument& ia) {
-		return std::tuple { 0.0, std::optional { "invalid argument" } };
+		return std::tuple<std::int64_t, _go_error> { 0, _go_error { "invalid argument" } };
 	}
 }
 `,
 		"strings.Contains":  `inline auto stringsContains(std::string const& haystack, std::string const& needle) -> bool { return haystack.find(needle) != std::string::npos; }`,
 		"strings.HasPrefix": `inline auto stringsHasPrefix(std::string const& haystack, std::string const& prefix) -> auto { return 0 == haystack.find(prefix); }`,
-		"_format_output": `template<typename T>
-using _str_t = decltype( std::declval<T&>()._str() );
-
-template<typename T>
-using _p_str_t = decltype( std::declval<T&>()->_str() );
+		"_format_output": `// _go_format_float formats a floating point number like fmt.Println does, with the fewest digits
+// that give the same number, and with an exponent if it is less than -4 or at least 6
+template <typename F>
+auto _go_format_float(F f) -> std::string
+{
+    if (std::isnan(f)) {
+        return "NaN";
+    } else if (std::isinf(f)) {
+        return f > 0 ? "+Inf" : "-Inf";
+    }
+    char buf[64];
+    std::string s(buf, std::to_chars(buf, buf + sizeof buf, f, std::chars_format::scientific).ptr);
+    std::string sign = s[0] == '-' ? "-" : "";
+    std::size_t e = s.find('e');
+    std::string digits = s.substr(sign.size(), e - sign.size());
+    if (digits.size() > 1) {
+        digits.erase(1, 1); // the decimal point
+    }
+    int exponent = std::stoi(s.substr(e + 1));
+    int length = static_cast<int>(digits.size());
+    if (exponent < -4 || exponent >= 6) {
+        std::string mantissa = length > 1 ? digits.substr(0, 1) + "." + digits.substr(1) : digits;
+        int a = exponent < 0 ? -exponent : exponent;
+        return sign + mantissa + "e" + (exponent < 0 ? "-" : "+") + (a < 10 ? "0" : "") + std::to_string(a);
+    } else if (exponent < 0) {
+        return sign + "0." + std::string(-exponent - 1, '0') + digits;
+    } else if (length <= exponent + 1) {
+        return sign + digits + std::string(exponent + 1 - length, '0');
+    }
+    return sign + digits.substr(0, exponent + 1) + "." + digits.substr(exponent + 1);
+}
 
 template <typename T> void _format_output(std::ostream& out, T x)
 {
     if constexpr (std::is_same<T, bool>::value) {
         out << std::boolalpha << x << std::noboolalpha;
     } else if constexpr (std::is_integral<T>::value) {
-        out << static_cast<int>(x);
-    } else if constexpr (std::is_object<T>::value && !std::is_pointer<T>::value && std::experimental::is_detected_v<_str_t, T>) {
+        out << +x; // the unary plus promotes char types to int, so that they are printed as numbers
+    } else if constexpr (std::is_floating_point<T>::value) {
+        out << _go_format_float(x);
+    } else if constexpr (requires { x._str(); }) {
         out << x._str();
-    } else if constexpr (std::is_object<T>::value && std::is_pointer<T>::value && std::experimental::is_detected_v<_p_str_t, T>) {
-        out << "&" << x->_str();
+    } else if constexpr (requires { x.real(); x.imag(); }) {
+        out << "(";
+        _format_output(out, x.real());
+        if ((!std::signbit(x.imag()) && !std::isinf(x.imag())) || std::isnan(x.imag())) {
+            out << "+";
+        }
+        _format_output(out, x.imag());
+        out << "i)";
+    } else if constexpr (requires { typename T::element_type; x.get(); }) {
+        if (x == nullptr) {
+            out << "<nil>";
+        } else if constexpr (requires { x->_str(); }) {
+            out << "&" << x->_str();
+        } else {
+            out << static_cast<const void*>(x.get());
+        }
+    } else if constexpr (requires { x.begin(); x.end(); } && !std::is_same<T, std::string>::value) {
+        out << "[";
+        bool first = true;
+        for (const auto& element : x) {
+            if (!first) {
+                out << " ";
+            }
+            first = false;
+            _format_output(out, element);
+        }
+        out << "]";
     } else {
         out << x;
     }
@@ -298,28 +464,1382 @@ std::string fmtSprintf(const std::string& fmt, T arg1, T arg2, T arg3, T arg4, T
 `,
 		"len": `
 template <typename T>
-inline auto len(T x) -> int { return x.size(); }
+inline auto len(T x) -> long long { return x.size(); }
 `,
-	}
-	if useFormatOutput && !haveStructs {
-		replacements["_format_output"] = `template <typename T> void _format_output(std::ostream& out, T x)
+		"_go_any": `
+// _go_type_name returns the name of a type in Go, for the messages of failed type assertions
+template <typename T>
+auto _go_type_name() -> std::string
 {
-    if constexpr (std::is_same<T, bool>::value) {
-        out << std::boolalpha << x << std::noboolalpha;
-    } else if constexpr (std::is_integral<T>::value) {
-        out << static_cast<int>(x);
+    if constexpr (std::is_same_v<T, bool>) {
+        return "bool";
+    } else if constexpr (std::is_same_v<T, std::string>) {
+        return "string";
+    } else if constexpr (std::is_same_v<T, long long>) {
+        return "int";
+    } else if constexpr (std::is_same_v<T, std::int64_t>) {
+        return "int64";
+    } else if constexpr (std::is_same_v<T, std::int32_t>) {
+        return "int32";
+    } else if constexpr (std::is_same_v<T, std::int16_t>) {
+        return "int16";
+    } else if constexpr (std::is_same_v<T, std::int8_t>) {
+        return "int8";
+    } else if constexpr (std::is_same_v<T, unsigned long long>) {
+        return "uint";
+    } else if constexpr (std::is_same_v<T, std::uint64_t>) {
+        return "uint64";
+    } else if constexpr (std::is_same_v<T, std::uint32_t>) {
+        return "uint32";
+    } else if constexpr (std::is_same_v<T, std::uint16_t>) {
+        return "uint16";
+    } else if constexpr (std::is_same_v<T, std::uint8_t>) {
+        return "uint8";
+    } else if constexpr (std::is_same_v<T, double>) {
+        return "float64";
+    } else if constexpr (std::is_same_v<T, float>) {
+        return "float32";
+    } else if constexpr (std::is_same_v<T, std::complex<double>>) {
+        return "complex128";
+    } else if constexpr (std::is_same_v<T, std::complex<float>>) {
+        return "complex64";
+    } else if constexpr (requires { typename T::element_type; }) {
+        return "*" + _go_type_name<typename T::element_type>();
+    } else if constexpr (requires { typename T::mapped_type; }) {
+        return "map[" + _go_type_name<typename T::key_type>() + "]" + _go_type_name<typename T::mapped_type>();
+    } else if constexpr (requires(const T& x) { x._capacity(); }) {
+        return "[]" + _go_type_name<typename T::value_type>();
+    } else if constexpr (requires { std::tuple_size<T>::value; }) {
+        return "[" + std::to_string(std::tuple_size<T>::value) + "]" + _go_type_name<typename T::value_type>();
     } else {
-        out << x;
+        return typeid(T).name();
+    }
+}
+
+// _go_any is a value of any type, like interface{} in Go. A default constructed _go_any is nil.
+class _go_any {
+    std::any _value;
+    std::function<void(std::ostream&)> _output;
+    std::string _type;
+
+public:
+    _go_any() = default;
+    _go_any(std::nullptr_t) {}
+    _go_any(const char* s) : _go_any(std::string { s }) {}
+    template <typename T>
+        requires(!std::is_same_v<T, _go_any>)
+    _go_any(T x) : _value { x }, _output { [x](std::ostream& out) { _format_output(out, x); } }, _type { _go_type_name<T>() } {}
+    auto operator==(std::nullptr_t) const -> bool { return !_value.has_value(); }
+    // _as returns a pointer to the value if it has the type T, or nullptr
+    template <typename T>
+    auto _as() const -> const T* { return std::any_cast<T>(&_value); }
+    auto _type_name() const -> std::string { return _value.has_value() ? _type : "nil"; }
+    auto _str() const -> std::string
+    {
+        if (!_output) {
+            return "<nil>";
+        }
+        std::stringstream ss;
+        _output(ss);
+        return ss.str();
+    }
+};
+`,
+		"_go_panic": `
+// _go_panic_error is thrown by panic()
+struct _go_panic_error {
+    _go_any value;
+};
+
+// _go_panic_state is a panic that is in progress. A panic is aborted when a deferred call
+// panics while it unwinds the stack, and it then ends when the new panic ends.
+struct _go_panic_state {
+    _go_any value;
+    bool recovered = false;
+    bool aborted = false;
+};
+
+// The panics that are in progress, with the last one unwinding the stack
+inline thread_local std::vector<_go_panic_state> _go_panics;
+
+// The name of the function that is called by the deferred call that is made while a panic
+// unwinds the stack, or "" for a function value. Only that function can recover the panic.
+inline thread_local const char* _go_panic_frame = nullptr;
+
+[[noreturn]] inline void _go_panic(const _go_any& value)
+{
+    _go_panics.push_back(_go_panic_state { value });
+    throw _go_panic_error { value };
+}
+
+constexpr bool _go_runtime_checks = ` + strconv.FormatBool(runtimeChecks) + `;
+
+// _go_runtime_error is the value of the panics that are caused by runtime errors
+struct _go_runtime_error {
+    std::string message;
+    bool signal = false;
+    auto _str() const -> std::string { return message; }
+};
+
+[[noreturn]] inline void _go_panic_runtime(const std::string& message, bool signal = false)
+{
+    _go_panic(_go_runtime_error { "runtime error: " + message, signal });
+}
+
+[[noreturn]] inline void _go_panic_index(std::int64_t i, std::size_t length)
+{
+    if (i < 0) {
+        _go_panic_runtime("index out of range [" + std::to_string(i) + "]");
+    }
+    _go_panic_runtime("index out of range [" + std::to_string(i) + "] with length " + std::to_string(length));
+}
+
+// recover() stops a panic when it is called by the given function, and the function is
+// called directly by a deferred call while the panic unwinds the stack
+inline auto _go_panic_recover(const char* function) -> _go_any
+{
+    if (_go_panic_frame == nullptr || (*_go_panic_frame != '\0' && std::string_view { _go_panic_frame } != function)) {
+        return nullptr;
+    }
+    if (_go_panics.empty() || _go_panics.back().recovered) {
+        return nullptr;
+    }
+    _go_panics.back().recovered = true;
+    return _go_panics.back().value;
+}
+
+// _go_panic_end is called after the deferred calls of a function have been made while the
+// panic at the given index unwinds the stack. The function returns normally if the last
+// panic was recovered, and the panic, the panics that replaced it and the panics that they
+// aborted then end. Otherwise the last panic continues.
+inline void _go_panic_end(std::size_t index)
+{
+    if (!_go_panics.back().recovered) {
+        throw _go_panic_error { _go_panics.back().value };
+    }
+    _go_panics.resize(index);
+    while (!_go_panics.empty() && _go_panics.back().aborted) {
+        _go_panics.pop_back();
+    }
+}
+
+// Output the unrecovered panic, and the panics that it aborted, like Go does, and return the exit code
+inline auto _go_panic_exit(const _go_panic_error&) -> int
+{
+    std::cout.flush();
+    for (std::size_t i = 0; i < _go_panics.size(); i++) {
+        const auto& p = _go_panics[i];
+        std::cerr << (i > 0 ? "\tpanic: " : "panic: ") << p.value._str() << (p.recovered ? " [recovered]" : "") << "\n";
+        if (auto r = p.value._as<_go_runtime_error>(); r && r->signal) {
+            std::cerr << "[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x0]\n";
+        }
+    }
+    std::cerr << "\ngoroutine 1 [running]:\n";
+    return 2;
+}
+`,
+		"_go_defer": `
+// _go_defer_stack holds the deferred calls of a function, that are made in reverse order before it returns
+class _go_defer_stack {
+    struct _call {
+        const char* function; // the name of the function that is called, for recover()
+        std::function<void()> f;
+    };
+    std::vector<_call> _calls;
+
+public:
+    // push defers a call to f. The arguments are evaluated when the call is deferred, not when it is made.
+    template <typename F, typename... A>
+    void push(const char* function, F f, A... args)
+    {
+        _calls.push_back(_call { function, [f, args...]() mutable { f(args...); } });
+    }
+    // run makes the deferred calls when the function returns
+    void run()
+    {
+        while (!_calls.empty()) {
+            auto call = std::move(_calls.back());
+            _calls.pop_back();
+            auto frame = std::exchange(_go_panic_frame, nullptr);
+            try {
+                call.f();
+            } catch (...) {
+                _go_panic_frame = frame;
+                throw;
+            }
+            _go_panic_frame = frame;
+        }
+    }
+    // unwind makes the deferred calls from the catch block of the function, when a panic
+    // unwinds the stack. A panic in a deferred call aborts the panics of the function and
+    // replaces them, and the rest of the calls are still made.
+    void unwind()
+    {
+        std::size_t index = _go_panics.size() - 1;
+        while (!_calls.empty()) {
+            auto call = std::move(_calls.back());
+            _calls.pop_back();
+            auto frame = std::exchange(_go_panic_frame, call.function);
+            try {
+                call.f();
+            } catch (const _go_panic_error&) {
+                for (std::size_t i = index; i + 1 < _go_panics.size(); i++) {
+                    _go_panics[i].aborted = true;
+                }
+            }
+            _go_panic_frame = frame;
+        }
+        _go_panic_end(index);
+    }
+};
+`,
+		"_go_chan": `
+// _go_deadlock ends the program when the only goroutine would wait forever, like Go does
+[[noreturn]] inline void _go_deadlock(const std::string& waiting)
+{
+    std::cout.flush();
+    std::cerr << "fatal error: all goroutines are asleep - deadlock!\n\ngoroutine 1 [" << waiting << "]:\n";
+    std::exit(2);
+}
+
+// _go_chan is a channel. There are no goroutines, so the values are sent to the
+// buffer of the channel, and an unbuffered or full channel can not be sent to.
+template <typename T>
+class _go_chan {
+    struct _state {
+        std::deque<T> buffer;
+        std::size_t cap = 0;
+        bool closed = false;
+    };
+    std::shared_ptr<_state> _s;
+
+public:
+    _go_chan() = default;
+    _go_chan(std::nullptr_t) {}
+    static auto make(std::int64_t cap = 0) -> _go_chan
+    {
+        if (cap < 0) {
+            _go_panic_runtime("makechan: size out of range");
+        }
+        _go_chan c;
+        c._s = std::make_shared<_state>();
+        c._s->cap = static_cast<std::size_t>(cap);
+        return c;
+    }
+    auto operator==(std::nullptr_t) const -> bool { return !_s; }
+    auto operator==(const _go_chan& other) const -> bool { return _s == other._s; }
+    auto size() const -> std::size_t { return _s ? _s->buffer.size() : 0; }
+    auto _capacity() const -> std::size_t { return _s ? _s->cap : 0; }
+    void _send(T value) const
+    {
+        if (!_s) {
+            _go_deadlock("chan send (nil chan)");
+        }
+        if (_s->closed) {
+            _go_panic(_go_runtime_error { "send on closed channel" });
+        }
+        if (_s->buffer.size() >= _s->cap) {
+            _go_deadlock("chan send");
+        }
+        _s->buffer.push_back(std::move(value));
+    }
+    auto _send_ready() const -> bool { return _s && (_s->closed || _s->buffer.size() < _s->cap); }
+    auto _recv_ready() const -> bool { return _s && (_s->closed || !_s->buffer.empty()); }
+    auto _recv_ok() const -> std::tuple<T, bool>
+    {
+        if (!_s) {
+            _go_deadlock("chan receive (nil chan)");
+        }
+        if (!_s->buffer.empty()) {
+            T value = std::move(_s->buffer.front());
+            _s->buffer.pop_front();
+            return { std::move(value), true };
+        }
+        if (!_s->closed) {
+            _go_deadlock("chan receive");
+        }
+        return { T {}, false };
+    }
+    void _close() const
+    {
+        if (!_s) {
+            _go_panic(_go_runtime_error { "close of nil channel" });
+        }
+        if (_s->closed) {
+            _go_panic(_go_runtime_error { "close of closed channel" });
+        }
+        _s->closed = true;
+    }
+    auto _str() const -> std::string
+    {
+        std::stringstream ss;
+        ss << static_cast<const void*>(_s.get());
+        return ss.str();
+    }
+
+    // _receiver is the range of a channel, that receives values until the channel is closed
+    class _receiver {
+        _go_chan _c;
+
+    public:
+        class iterator {
+            const _go_chan* _c;
+            std::optional<T> _value;
+
+        public:
+            iterator(const _go_chan* c) : _c { c }
+            {
+                if (_c) {
+                    ++*this;
+                }
+            }
+            auto operator*() const { return std::pair { *_value, *_value }; }
+            auto operator++() -> iterator&
+            {
+                auto [value, ok] = _c->_recv_ok();
+                _value.reset();
+                if (ok) {
+                    _value = std::move(value);
+                }
+                return *this;
+            }
+            auto operator!=(const iterator&) const -> bool { return _value.has_value(); }
+        };
+        _receiver(const _go_chan& c) : _c { c } {}
+        auto begin() const -> iterator { return iterator { &_c }; }
+        auto end() const -> iterator { return iterator { nullptr }; }
+    };
+    auto _range() const -> _receiver { return _receiver { *this }; }
+};
+
+template <typename T>
+auto _go_chan_make(std::int64_t cap = 0) -> _go_chan<T>
+{
+    return _go_chan<T>::make(cap);
+}
+
+template <typename T, typename V>
+void _go_chan_send(const _go_chan<T>& c, V&& value)
+{
+    c._send(std::forward<V>(value));
+}
+
+template <typename T>
+auto _go_chan_recv(const _go_chan<T>& c) -> T
+{
+    return std::get<0>(c._recv_ok());
+}
+
+template <typename T>
+auto _go_chan_recv_ok(const _go_chan<T>& c) -> std::tuple<T, bool>
+{
+    return c._recv_ok();
+}
+
+template <typename T>
+void _go_chan_close(const _go_chan<T>& c)
+{
+    c._close();
+}
+
+template <typename T>
+auto _go_chan_send_ready(const _go_chan<T>& c) -> bool
+{
+    return c._send_ready();
+}
+
+template <typename T>
+auto _go_chan_recv_ready(const _go_chan<T>& c) -> bool
+{
+    return c._recv_ready();
+}
+
+// _go_chan_select chooses one of the cases of a select statement that can proceed, at random
+// like Go does, and returns its index, or -1 for the default case if no case can proceed
+template <typename... B>
+auto _go_chan_select(bool has_default, B... ready) -> int
+{
+    const bool cases[] = { false, ready... };
+    std::vector<int> indices;
+    for (std::size_t i = 1; i < std::size(cases); i++) {
+        if (cases[i]) {
+            indices.push_back(static_cast<int>(i - 1));
+        }
+    }
+    if (!indices.empty()) {
+        static std::mt19937_64 generator { std::random_device {}() };
+        return indices[generator() % indices.size()];
+    }
+    if (!has_default) {
+        _go_deadlock(sizeof...(ready) == 0 ? "select (no cases)" : "select");
+    }
+    return -1;
+}
+`,
+		"_go_range": `
+// _go_decode_rune decodes the UTF-8 encoded rune at the given position in a string,
+// and returns it and its length. Invalid UTF-8 gives the replacement character and 1.
+inline auto _go_decode_rune(std::string_view s, std::size_t i) -> std::pair<std::int32_t, std::size_t>
+{
+    auto b = [&](std::size_t j) -> std::uint32_t { return static_cast<unsigned char>(s[i + j]); };
+    auto continuation = [&](std::size_t j) { return i + j < s.size() && (b(j) & 0xc0) == 0x80; };
+    auto rune = [](std::uint32_t r, std::size_t n) { return std::pair { static_cast<std::int32_t>(r), n }; };
+    std::uint32_t c = b(0);
+    if (c < 0x80) {
+        return rune(c, 1);
+    }
+    if (c >= 0xc2 && c < 0xe0 && continuation(1)) {
+        return rune((c & 0x1f) << 6 | (b(1) & 0x3f), 2);
+    }
+    if (c >= 0xe0 && c < 0xf0 && continuation(1) && continuation(2)) {
+        std::uint32_t r = (c & 0x0f) << 12 | (b(1) & 0x3f) << 6 | (b(2) & 0x3f);
+        if (r >= 0x800 && (r < 0xd800 || r > 0xdfff)) {
+            return rune(r, 3);
+        }
+    }
+    if (c >= 0xf0 && c < 0xf5 && continuation(1) && continuation(2) && continuation(3)) {
+        std::uint32_t r = (c & 0x07) << 18 | (b(1) & 0x3f) << 12 | (b(2) & 0x3f) << 6 | (b(3) & 0x3f);
+        if (r >= 0x10000 && r <= 0x10ffff) {
+            return rune(r, 4);
+        }
+    }
+    return rune(0xfffd, 1);
+}
+
+// _go_runes is the range of a string, that gives the byte index and the rune of each UTF-8 encoded rune
+class _go_runes {
+    std::string _s;
+
+public:
+    class iterator {
+        std::string_view _s;
+        std::size_t _i;
+
+    public:
+        iterator(std::string_view s, std::size_t i) : _s { s }, _i { i } {}
+        auto operator*() const { return std::pair { static_cast<long long>(_i), _go_decode_rune(_s, _i).first }; }
+        auto operator++() -> iterator& { _i += _go_decode_rune(_s, _i).second; return *this; }
+        auto operator!=(const iterator& other) const -> bool { return _i < other._i; }
+    };
+    _go_runes(std::string_view s) : _s { s } {}
+    auto begin() const -> iterator { return iterator { _s, 0 }; }
+    auto end() const -> iterator { return iterator { _s, _s.size() }; }
+};
+
+// _go_count is the range of an integer n, that gives the integers from 0 to n-1
+template <typename T>
+class _go_count {
+    T _n;
+
+public:
+    class iterator {
+        T _i;
+
+    public:
+        iterator(T i) : _i { i } {}
+        auto operator*() const { return std::pair { _i, _i }; }
+        auto operator++() -> iterator& { ++_i; return *this; }
+        auto operator!=(const iterator& other) const -> bool { return _i < other._i; }
+    };
+    _go_count(T n) : _n { n } {}
+    auto begin() const -> iterator { return iterator { 0 }; }
+    auto end() const -> iterator { return iterator { _n }; }
+};
+
+template <typename T>
+class _go_enumerate {
+    T _x;
+
+public:
+    class iterator {
+        const std::remove_reference_t<T>* _p;
+        long long _i;
+
+    public:
+        iterator(const std::remove_reference_t<T>* p, long long i) : _p { p }, _i { i } {}
+        auto operator*() const { return std::pair { _i, (*_p)[_i] }; }
+        auto operator++() -> iterator& { ++_i; return *this; }
+        auto operator!=(const iterator& other) const -> bool { return _i != other._i; }
+    };
+    _go_enumerate(T&& x) : _x { std::forward<T>(x) } {}
+    auto begin() const -> iterator { return iterator { &_x, 0 }; }
+    auto end() const -> iterator { return iterator { &_x, static_cast<long long>(std::size(_x)) }; }
+};
+
+// _go_range returns the key/value pairs of a map, the byte index/rune pairs of a string,
+// the integers up to an integer, the values that are received from a channel, or the
+// index/element pairs of anything else, like a slice, an array or a pointer to an array
+template <typename T>
+auto _go_range(T&& x)
+{
+    using U = std::remove_cvref_t<T>;
+    if constexpr (requires { typename U::mapped_type; }) {
+        return U { x };
+    } else if constexpr (std::is_convertible_v<const U&, std::string_view>) {
+        return _go_runes { std::string_view { x } };
+    } else if constexpr (std::is_integral_v<U>) {
+        return _go_count<U> { x };
+    } else if constexpr (requires { typename U::element_type; }) {
+        if constexpr (_go_runtime_checks) {
+            if (x == nullptr) {
+                _go_panic_runtime("invalid memory address or nil pointer dereference", true);
+            }
+        }
+        return _go_enumerate<typename U::element_type&> { *x };
+    } else if constexpr (requires { x._range(); }) {
+        return x._range();
+    } else {
+        return _go_enumerate<T> { std::forward<T>(x) };
+    }
+}
+
+// _go_range_keys is _go_range, for loops that only use the keys. A pointer to an
+// array is then not dereferenced, since the length of the array is known.
+template <typename T>
+auto _go_range_keys(T&& x)
+{
+    using U = std::remove_cvref_t<T>;
+    if constexpr (requires { typename U::element_type; }) {
+        return _go_count<long long> { static_cast<long long>(std::tuple_size_v<typename U::element_type>) };
+    } else {
+        return _go_range(std::forward<T>(x));
+    }
+}
+`,
+		"_go_ref": `
+// _go_ref returns a reference to an element, for assigning to it. Map entries are created if needed.
+template <typename T, typename I>
+auto _go_ref(T&& x, const I& i) -> decltype(auto)
+{
+    if constexpr (requires { x._at(i); }) {
+        return x._at(i);
+    } else {
+        return _go_index(x, i);
+    }
+}
+`,
+		"_go_index": `
+// _go_index returns x[i]. Maps return the zero value for missing keys, everything else is bounds checked.
+template <typename T, typename I>
+auto _go_index(T&& x, const I& i) -> decltype(auto)
+{
+    using V = std::remove_cvref_t<T>;
+    if constexpr (std::is_array_v<V> && std::is_same_v<std::remove_cv_t<std::remove_extent_t<V>>, char>) {
+        // A string literal, without the NUL that ends the array
+        return _go_index(std::string_view { x, std::size(x) - 1 }, i);
+    } else if constexpr (requires { typename V::mapped_type; }) {
+        return x[i];
+    } else {
+        if constexpr (_go_runtime_checks && !requires { x._capacity(); }) {
+            if (static_cast<std::int64_t>(i) < 0 || static_cast<std::int64_t>(i) >= static_cast<std::int64_t>(std::size(x))) {
+                _go_panic_index(i, std::size(x));
+            }
+        }
+        if constexpr (std::is_same_v<V, std::string> || std::is_same_v<V, std::string_view>) {
+            return static_cast<std::uint8_t>(x[i]);
+        } else {
+            return (x[i]);
+        }
+    }
+}
+`,
+		"_go_slice": `
+// _go_slice is a Go slice, a view into a shared backing array. A default constructed slice is a nil slice.
+template <typename T>
+class _go_slice {
+    std::shared_ptr<T[]> _data;
+    std::size_t _offset = 0;
+    std::size_t _len = 0;
+    std::size_t _cap = 0;
+
+public:
+    using value_type = T;
+
+    _go_slice() = default;
+    _go_slice(std::nullptr_t) {}
+    _go_slice(std::initializer_list<T> elements)
+        : _data { std::make_shared<T[]>(elements.size()) }
+        , _len { elements.size() }
+        , _cap { elements.size() }
+    {
+        std::copy(elements.begin(), elements.end(), _data.get());
+    }
+    static auto make(std::int64_t len, std::int64_t cap) -> _go_slice
+    {
+        if (len < 0) {
+            _go_panic_runtime("makeslice: len out of range");
+        }
+        if (cap < len) {
+            _go_panic_runtime("makeslice: cap out of range");
+        }
+        _go_slice s;
+        s._data = std::make_shared<T[]>(cap);
+        s._len = len;
+        s._cap = cap;
+        return s;
+    }
+    static auto make(std::int64_t len) -> _go_slice { return make(len, len); }
+    auto operator[](std::int64_t i) const -> T&
+    {
+        if constexpr (_go_runtime_checks) {
+            if (i < 0 || static_cast<std::size_t>(i) >= _len) {
+                _go_panic_index(i, _len);
+            }
+        }
+        return _data[_offset + i];
+    }
+    auto size() const -> std::size_t { return _len; }
+    auto _capacity() const -> std::size_t { return _cap; }
+    auto begin() const -> T* { return _data.get() + _offset; }
+    auto end() const -> T* { return begin() + _len; }
+    auto operator==(std::nullptr_t) const -> bool { return !_data; }
+    // _owner returns the backing array, which is kept alive by pointers to the elements
+    auto _owner() const -> const std::shared_ptr<T[]>& { return _data; }
+    // _slice returns s[low:high] or s[low:high:max], sharing the backing array
+    auto _slice(std::int64_t low, std::int64_t high, std::int64_t max = -1) const -> _go_slice
+    {
+        if constexpr (_go_runtime_checks) {
+            auto n = [](std::int64_t x) { return std::to_string(x); };
+            std::int64_t cap = _cap;
+            if (max == -1 && (high < 0 || high > cap)) {
+                _go_panic_runtime("slice bounds out of range [:" + n(high) + "] with capacity " + n(cap));
+            } else if (max != -1 && (max < 0 || max > cap)) {
+                _go_panic_runtime("slice bounds out of range [::" + n(max) + "] with capacity " + n(cap));
+            } else if (max != -1 && (high < 0 || high > max)) {
+                _go_panic_runtime("slice bounds out of range [:" + n(high) + ":" + n(max) + "]");
+            } else if (low < 0 || low > high) {
+                _go_panic_runtime("slice bounds out of range [" + n(low) + ":" + n(high) + (max == -1 ? "]" : ":]"));
+            }
+        }
+        _go_slice s = *this;
+        s._offset = _offset + low;
+        s._len = high - low;
+        s._cap = (max == -1 ? _cap : max) - low;
+        return s;
+    }
+    // _append returns the slice with the elements appended, growing the backing array like Go if needed
+    auto _append(std::initializer_list<T> elements) const -> _go_slice { return _append_all(elements); }
+    // _append_all appends the elements of a slice, a string or an initializer list
+    template <typename R>
+    auto _append_all(const R& elements) const -> _go_slice
+    {
+        _go_slice s = *this;
+        std::size_t needed = _len + std::size(elements);
+        if (needed > _cap) {
+            std::size_t newcap = _cap;
+            if (needed > 2 * _cap) {
+                newcap = needed;
+            } else if (_cap < 256) {
+                newcap = 2 * _cap;
+            } else {
+                while (newcap < needed) {
+                    newcap += (newcap + 3 * 256) / 4;
+                }
+            }
+            s._data = std::make_shared<T[]>(newcap);
+            std::copy(begin(), end(), s._data.get());
+            s._offset = 0;
+            s._cap = newcap;
+        }
+        std::copy(std::begin(elements), std::end(elements), s.begin() + _len);
+        s._len = needed;
+        return s;
+    }
+};
+
+template <typename T, typename... A>
+auto _go_append(const _go_slice<T>& s, A&&... elements) -> _go_slice<T>
+{
+    return s._append({ T(std::forward<A>(elements))... });
+}
+
+// _go_append_slice appends the elements of a slice, or the bytes of a string, like append(s, t...)
+template <typename T, typename R>
+auto _go_append_slice(const _go_slice<T>& s, const R& elements) -> _go_slice<T>
+{
+    if constexpr (std::is_convertible_v<const R&, std::string_view>) {
+        return s._append_all(std::string_view { elements });
+    } else {
+        return s._append_all(elements);
+    }
+}
+
+template <typename T>
+inline auto _go_cap(const T& x) -> long long
+{
+    if constexpr (requires { x._capacity(); }) {
+        return x._capacity();
+    } else {
+        return std::size(x);
+    }
+}
+`,
+		"_go_sprint": `
+// _go_any_cast stores the value of x in result, if x holds one of the types T
+template <typename R, typename... T>
+auto _go_any_cast(const _go_any& x, R& result) -> bool
+{
+    return ((x._as<T>() && (result = static_cast<R>(*x._as<T>()), true)) || ...);
+}
+
+// _go_sprint formats the values like fmt.Sprint, with spaces between operands when neither is a string
+inline auto _go_sprint(const _go_slice<_go_any>& args) -> std::string
+{
+    std::string s;
+    bool previousString = true;
+    for (const auto& arg : args) {
+        bool isString = arg._type_name() == "string";
+        if (!isString && !previousString) {
+            s += " ";
+        }
+        s += arg._str();
+        previousString = isString;
+    }
+    return s;
+}
+
+// _go_sprintln formats the values like fmt.Sprintln, with spaces between all operands and a newline
+inline auto _go_sprintln(const _go_slice<_go_any>& args) -> std::string
+{
+    std::string s;
+    for (const auto& arg : args) {
+        if (!s.empty()) {
+            s += " ";
+        }
+        s += arg._str();
+    }
+    return s + "\n";
+}
+
+// _go_sprintf formats the values like fmt.Sprintf. The flags, width and precision are given to snprintf.
+inline auto _go_sprintf(const std::string& format, const _go_slice<_go_any>& args) -> std::string
+{
+    auto printf = [](const std::string& spec, auto value) {
+        std::string s(std::snprintf(nullptr, 0, spec.c_str(), value), '\0');
+        std::snprintf(s.data(), s.size() + 1, spec.c_str(), value);
+        return s;
+    };
+    auto bad = [](char verb, const _go_any& arg) {
+        return "%!" + std::string(1, verb) + "(" + arg._type_name() + "=" + arg._str() + ")";
+    };
+    std::string s;
+    std::size_t n = 0;
+    for (std::size_t i = 0; i < format.size(); i++) {
+        if (format[i] != '%') {
+            s += format[i];
+            continue;
+        }
+        std::size_t start = i++;
+        while (i < format.size() && std::string("+-# 0123456789.").find(format[i]) != std::string::npos) {
+            i++;
+        }
+        if (i == format.size()) {
+            s += "%!(NOVERB)";
+            break;
+        }
+        char verb = format[i];
+        std::string spec = format.substr(start, i - start);
+        if (verb == '%') {
+            s += "%";
+            continue;
+        }
+        if (n == args.size()) {
+            s += "%!" + std::string(1, verb) + "(MISSING)";
+            continue;
+        }
+        const _go_any& arg = args[n++];
+        long long integer = 0;
+        double floating = 0;
+        bool isInteger = _go_any_cast<long long, char, signed char, short, int, long, long long, unsigned char, unsigned short, unsigned int, unsigned long, unsigned long long>(arg, integer);
+        bool isFloating = _go_any_cast<double, float, double>(arg, floating);
+        switch (verb) {
+        case 'v':
+        case 's':
+        case 't':
+            if (verb == 's' && (isInteger || isFloating)) {
+                s += bad(verb, arg);
+            } else {
+                s += printf(spec + "s", arg._str().c_str());
+            }
+            break;
+        case 'T':
+            s += printf(spec + "s", arg._type_name().c_str());
+            break;
+        case 'q':
+            if (auto p = arg._as<std::string>()) {
+                std::stringstream ss;
+                ss << std::quoted(*p);
+                s += printf(spec + "s", ss.str().c_str());
+            } else {
+                s += bad(verb, arg);
+            }
+            break;
+        case 'd':
+        case 'x':
+        case 'X':
+        case 'o':
+            if (isInteger) {
+                s += printf(spec + "ll" + verb, integer);
+            } else {
+                s += bad(verb, arg);
+            }
+            break;
+        case 'c':
+            if (isInteger) {
+                std::string r;
+                if (integer < 0x80) {
+                    r += static_cast<char>(integer);
+                } else if (integer < 0x800) {
+                    r += static_cast<char>(0xc0 | (integer >> 6));
+                    r += static_cast<char>(0x80 | (integer & 0x3f));
+                } else if (integer < 0x10000) {
+                    r += static_cast<char>(0xe0 | (integer >> 12));
+                    r += static_cast<char>(0x80 | ((integer >> 6) & 0x3f));
+                    r += static_cast<char>(0x80 | (integer & 0x3f));
+                } else {
+                    r += static_cast<char>(0xf0 | (integer >> 18));
+                    r += static_cast<char>(0x80 | ((integer >> 12) & 0x3f));
+                    r += static_cast<char>(0x80 | ((integer >> 6) & 0x3f));
+                    r += static_cast<char>(0x80 | (integer & 0x3f));
+                }
+                s += printf(spec + "s", r.c_str());
+            } else {
+                s += bad(verb, arg);
+            }
+            break;
+        case 'f':
+        case 'F':
+        case 'e':
+        case 'E':
+        case 'g':
+        case 'G':
+            if (isFloating) {
+                s += printf(spec + verb, floating);
+            } else {
+                s += bad(verb, arg);
+            }
+            break;
+        default:
+            s += bad(verb, arg);
+        }
+    }
+    if (n < args.size()) {
+        s += "%!(EXTRA ";
+        for (std::size_t i = n; i < args.size(); i++) {
+            if (i > n) {
+                s += ", ";
+            }
+            s += args[i]._type_name() + "=" + args[i]._str();
+        }
+        s += ")";
+    }
+    return s;
+}
+`,
+		"_go_slicing": `
+// _go_end is the high bound of slice expressions where it is omitted, like s[1:]
+struct _go_end_t { };
+constexpr _go_end_t _go_end;
+
+// _go_slicing returns x[low:high] or x[low:high:max]. Slicing a slice shares the backing array,
+// while slicing a string returns a new string. Slicing an array copies the elements into a new slice.
+template <typename T, typename L, typename H, typename... M>
+auto _go_slicing(const T& x, L low, H high, M... max)
+{
+    std::int64_t h;
+    if constexpr (std::is_same_v<H, _go_end_t>) {
+        h = std::size(x);
+    } else {
+        h = high;
+    }
+    if constexpr (requires { x._capacity(); }) {
+        return x._slice(low, h, max...);
+    } else {
+        if constexpr (_go_runtime_checks) {
+            std::int64_t length = std::size(x);
+            if (h < 0 || h > length) {
+                _go_panic_runtime("slice bounds out of range [:" + std::to_string(h) + "] with length " + std::to_string(length));
+            } else if (low < 0 || low > h) {
+                _go_panic_runtime("slice bounds out of range [" + std::to_string(low) + ":" + std::to_string(h) + "]");
+            }
+        }
+        if constexpr (std::is_same_v<T, std::string>) {
+            return x.substr(low, h - low);
+        } else {
+            auto s = _go_slice<typename T::value_type>::make(h - low);
+            std::copy(x.begin() + low, x.begin() + h, s.begin());
+            return s;
+        }
+    }
+}
+`,
+		"_go_convert": `
+// _go_truncate64 and _go_truncate32 truncate a floating point number to an integer like amd64 does,
+// where a number that does not fit, and NaN, gives the smallest integer
+constexpr auto _go_truncate64(double f) -> std::int64_t
+{
+    if (f >= -9223372036854775808.0 && f < 9223372036854775808.0) {
+        return static_cast<std::int64_t>(f);
+    }
+    return std::numeric_limits<std::int64_t>::min();
+}
+
+constexpr auto _go_truncate32(double f) -> std::int32_t
+{
+    if (f > -2147483649.0 && f < 2147483648.0) {
+        return static_cast<std::int32_t>(f);
+    }
+    return std::numeric_limits<std::int32_t>::min();
+}
+
+// _go_convert converts a value to the given numeric type, like T(x) in Go. Integers wrap around,
+// and floating point numbers are truncated like Go does it on amd64.
+template <typename T, typename V>
+constexpr T _go_convert(const V& value)
+{
+    if constexpr (std::is_integral_v<T> && std::is_floating_point_v<V>) {
+        if constexpr (std::is_unsigned_v<T> && sizeof(T) == 8) {
+            if (value < 9223372036854775808.0) {
+                return static_cast<T>(_go_truncate64(value));
+            }
+            return static_cast<T>(_go_truncate64(value - 9223372036854775808.0)) | (T(1) << 63);
+        } else if constexpr (sizeof(T) == 8 || (std::is_unsigned_v<T> && sizeof(T) == 4)) {
+            return static_cast<T>(_go_truncate64(value));
+        } else {
+            return static_cast<T>(_go_truncate32(value));
+        }
+    } else {
+        return static_cast<T>(value);
+    }
+}
+`,
+		"_go_complex": `
+// _go_complex makes a complex number from the real and imaginary parts, like complex(re, im) in Go
+template <typename T>
+constexpr auto _go_complex(T re, T im) -> std::complex<T>
+{
+    return std::complex<T> { re, im };
+}
+
+// _go_complex_real returns the real part of a complex number, like real(c) in Go
+template <typename T>
+constexpr auto _go_complex_real(const std::complex<T>& c) -> T { return c.real(); }
+
+// _go_complex_imag returns the imaginary part of a complex number, like imag(c) in Go
+template <typename T>
+constexpr auto _go_complex_imag(const std::complex<T>& c) -> T { return c.imag(); }
+`,
+		"cmplx.Abs":   `inline auto cmplxAbs(std::complex<double> x) -> double { return std::abs(x); }`,
+		"cmplx.Phase": `inline auto cmplxPhase(std::complex<double> x) -> double { return std::arg(x); }`,
+		"cmplx.Exp":   `inline auto cmplxExp(std::complex<double> x) -> std::complex<double> { return std::exp(x); }`,
+		"cmplx.Sqrt":  `inline auto cmplxSqrt(std::complex<double> x) -> std::complex<double> { return std::sqrt(x); }`,
+		"cmplx.Polar": `inline auto cmplxPolar(std::complex<double> x) -> std::tuple<double, double> { return std::tuple { std::abs(x), std::arg(x) }; }`,
+		"cmplx.Rect":  `inline auto cmplxRect(double r, double theta) -> std::complex<double> { return std::polar(r, theta); }`,
+		"cmplx.Conj":  `inline auto cmplxConj(std::complex<double> x) -> std::complex<double> { return std::conj(x); }`,
+		"strings.Split": `
+// stringsSplit splits a string at each separator, like strings.Split, or after each UTF-8 sequence if the separator is empty
+inline auto stringsSplit(const std::string& s, const std::string& sep) -> _go_slice<std::string>
+{
+    std::vector<std::string> parts;
+    if (sep.empty()) {
+        for (std::size_t i = 0; i < s.size();) {
+            std::size_t n = 1;
+            while (i + n < s.size() && (static_cast<unsigned char>(s[i + n]) & 0xc0) == 0x80 && n < 4) {
+                n++;
+            }
+            parts.push_back(s.substr(i, n));
+            i += n;
+        }
+    } else {
+        std::size_t start = 0;
+        for (std::size_t pos = s.find(sep); pos != std::string::npos; pos = s.find(sep, start)) {
+            parts.push_back(s.substr(start, pos - start));
+            start = pos + sep.size();
+        }
+        parts.push_back(s.substr(start));
     }
-}`
+    return _go_slice<std::string>::make(0)._append_all(parts);
+}
+`,
+		"_go_div": `
+// _go_div divides like Go. Integer division by zero panics, and the most negative integer divided by -1 overflows.
+template <typename A, typename B>
+auto _go_div(A a, B b)
+{
+    using R = decltype(a / b);
+    if constexpr (std::is_integral_v<A> && std::is_integral_v<B>) {
+        if constexpr (_go_runtime_checks) {
+            if (b == 0) {
+                _go_panic_runtime("integer divide by zero");
+            }
+        }
+        if constexpr (std::is_signed_v<R>) {
+            if (b == -1) {
+                return static_cast<R>(-static_cast<std::make_unsigned_t<R>>(a));
+            }
+        }
+    }
+    return static_cast<R>(a / b);
+}
+`,
+		"_go_mod": `
+// _go_mod returns the remainder like Go. The remainder of an integer division by zero panics.
+template <typename A, typename B>
+auto _go_mod(A a, B b)
+{
+    using R = decltype(a % b);
+    if constexpr (_go_runtime_checks) {
+        if (b == 0) {
+            _go_panic_runtime("integer divide by zero");
+        }
+    }
+    if constexpr (std::is_signed_v<R>) {
+        if (b == -1) {
+            return static_cast<R>(0);
+        }
+    }
+    return static_cast<R>(a % b);
+}
+`,
+		"_go_shift": `
+// _go_shift_left shifts like Go, where shifting by the width of the type or more gives 0.
+// A negative shift count panics.
+template <typename T, typename N>
+constexpr T _go_shift_left(T x, N n)
+{
+    if constexpr (std::is_signed_v<N>) {
+        if (n < 0) {
+            _go_panic_runtime("negative shift amount");
+        }
+    }
+    if (static_cast<std::uint64_t>(n) >= sizeof(T) * 8) {
+        return 0;
+    }
+    return static_cast<T>(static_cast<std::make_unsigned_t<T>>(x) << n);
+}
+
+// _go_shift_right shifts like Go, where shifting by the width of the type or more gives 0,
+// or -1 for negative numbers. A negative shift count panics.
+template <typename T, typename N>
+constexpr T _go_shift_right(T x, N n)
+{
+    if constexpr (std::is_signed_v<N>) {
+        if (n < 0) {
+            _go_panic_runtime("negative shift amount");
+        }
+    }
+    if (static_cast<std::uint64_t>(n) >= sizeof(T) * 8) {
+        if constexpr (std::is_signed_v<T>) {
+            return x < 0 ? -1 : 0;
+        }
+        return 0;
+    }
+    return static_cast<T>(x >> n);
+}
+`,
+		"_go_complement": `
+// _go_complement returns the bitwise complement of an integer, like ^x in Go, with the same type
+template <typename T>
+constexpr T _go_complement(T x)
+{
+    return static_cast<T>(~x);
+}
+`,
+		"_go_ptr": pointerRuntime[memoryManagement],
+		"_go_new": newRuntime[memoryManagement],
+		"_go_address": `
+// _go_address returns a pointer to a part of a value, like &x.f and &s[i] in Go. The owners are
+// the pointers and slices that the part may be in, from the innermost one. The first one that
+// is a pointer or a slice keeps the value alive. Parts of other values, like package level
+// variables, live for as long as the program.
+template <typename T, typename... O>
+auto _go_address(T* p, const O&... owners) -> _go_ptr<T>
+{
+    std::shared_ptr<T> owned;
+    auto own = [&](const auto& owner) {
+        if constexpr (requires { owner._owner(); }) {
+            owned = std::shared_ptr<T>(owner._owner(), p);
+            return true;
+        } else {
+            return false;
+        }
+    };
+    if (!(own(owners) || ...)) {
+        owned = std::shared_ptr<T>(std::shared_ptr<T> {}, p);
+    }
+    return _go_ptr<T> { owned };
+}
+`,
+		"_go_deref": `
+// _go_deref dereferences a pointer, and panics if it is nil
+template <typename P>
+auto _go_deref(const P& p) -> decltype(*p)
+{
+    if constexpr (_go_runtime_checks) {
+        if (p == nullptr) {
+            _go_panic_runtime("invalid memory address or nil pointer dereference", true);
+        }
+    }
+    return *p;
+}
+`,
+		"_go_func": `
+// _go_func is a function value. A default constructed _go_func is nil, and calling it panics.
+template <typename F>
+class _go_func;
+
+template <typename R, typename... A>
+class _go_func<R(A...)> : public std::function<R(A...)> {
+public:
+    using std::function<R(A...)>::function;
+    auto operator()(A... args) const -> R
+    {
+        if constexpr (_go_runtime_checks) {
+            if (!*this) {
+                _go_panic_runtime("invalid memory address or nil pointer dereference", true);
+            }
+        }
+        return std::function<R(A...)>::operator()(std::forward<A>(args)...);
+    }
+    auto _str() const -> std::string
+    {
+        if (!*this) {
+            return "<nil>";
+        }
+        std::stringstream ss;
+        ss << static_cast<const void*>(this);
+        return ss.str();
+    }
+};
+`,
+		"_go_box": `
+// _go_box allocates a variable that is captured by function literals, or that has its address
+// taken, so that it lives for as long as it is used
+template <typename T, typename... A>
+auto _go_box(A&&... value) -> _go_ptr<T>
+{
+    return _go_new<T>(std::forward<A>(value)...);
+}
+
+// _go_rebox gives a loop variable a new box for the next iteration, like Go 1.22 does
+template <typename T>
+auto _go_rebox(_go_ptr<T>& box) -> T&
+{
+    box = _go_new<T>(*box);
+    return *box;
+}
+`,
+		"_go_assert": `
+// _go_assert returns the value of x.(T), and panics if x does not hold a T
+template <typename T>
+auto _go_assert(const _go_any& x) -> T
+{
+    if (auto p = x._as<T>()) {
+        return *p;
+    }
+    _go_panic(_go_runtime_error { "interface conversion: interface {} is " + x._type_name() + ", not " + _go_type_name<T>() });
+}
+
+// _go_assert_ok returns the value of x.(T) and true, or the zero value and false if x does not hold a T
+template <typename T>
+auto _go_assert_ok(const _go_any& x) -> std::tuple<T, bool>
+{
+    if (auto p = x._as<T>()) {
+        return std::tuple { *p, true };
+    }
+    return std::tuple { T {}, false };
+}
+`,
+		"_go_struct": `
+// _go_struct is the empty struct type, like struct{} in Go
+struct _go_struct {
+    auto operator==(const _go_struct&) const -> bool = default;
+    auto _hash() const -> std::size_t { return 0; }
+    auto _str() const -> std::string { return "{}"; }
+};
+`,
+		"_go_hash": `
+inline auto _go_hash_combine(std::size_t seed, std::size_t h) -> std::size_t
+{
+    return seed ^ (h + 0x9e3779b97f4a7c15ULL + (seed << 6) + (seed >> 2));
+}
+
+// _go_hash hashes structs with a _hash() method, arrays element by element and everything else with std::hash
+template <typename T>
+struct _go_hash {
+    auto operator()(const T& x) const -> std::size_t
+    {
+        if constexpr (requires { x._hash(); }) {
+            return x._hash();
+        } else if constexpr (requires { std::tuple_size<T>::value; }) {
+            std::size_t h = 0;
+            for (const auto& element : x) {
+                h = _go_hash_combine(h, _go_hash<std::remove_cvref_t<decltype(element)>> {}(element));
+            }
+            return h;
+        } else {
+            return std::hash<T> {}(x);
+        }
+    }
+};
+`,
+		"_go_map": `
+enum class _go_map_order { unordered, random, insertion };
+constexpr auto _go_map_iteration = _go_map_order::` + mapIterationOrder + `;
+
+// _go_map is a Go map. A default constructed map is a nil map.
+template <typename K, typename V>
+class _go_map {
+    struct _entry {
+        V value;
+        std::uint64_t inserted;
+    };
+    struct _data {
+        std::unordered_map<K, _entry, _go_hash<K>> entries;
+        std::uint64_t insertions = 0;
+    };
+    std::shared_ptr<_data> _d;
+
+public:
+    using key_type = K;
+    using mapped_type = V;
+
+    // The keys are collected when a range loop starts, so that entries can be deleted while iterating
+    class iterator {
+        const _go_map* _m;
+        std::shared_ptr<std::vector<K>> _keys;
+        std::size_t _i;
+        void skip_deleted()
+        {
+            while (_keys && _i < _keys->size() && !_m->_d->entries.contains((*_keys)[_i])) {
+                ++_i;
+            }
+        }
+
+    public:
+        iterator(const _go_map* m, std::shared_ptr<std::vector<K>> keys, std::size_t i) : _m { m }, _keys { keys }, _i { i } { skip_deleted(); }
+        auto operator*() const -> std::pair<K, V> { return std::pair { (*_keys)[_i], _m->_d->entries.at((*_keys)[_i]).value }; }
+        auto operator++() -> iterator&
+        {
+            ++_i;
+            skip_deleted();
+            return *this;
+        }
+        auto operator!=(const iterator& other) const -> bool { return _i != other._i; }
+    };
+
+    _go_map() = default;
+    _go_map(std::nullptr_t) {}
+    _go_map(std::initializer_list<std::pair<const K, V>> elements)
+        : _d { std::make_shared<_data>() }
+    {
+        for (const auto& [k, v] : elements) {
+            _at(k) = v;
+        }
+    }
+    static auto make(std::size_t hint = 0) -> _go_map
+    {
+        _go_map m;
+        m._d = std::make_shared<_data>();
+        m._d->entries.reserve(hint);
+        return m;
+    }
+    // Reading a missing key returns the zero value, without inserting it
+    auto operator[](const K& k) const -> V
+    {
+        if (_d) {
+            if (auto it = _d->entries.find(k); it != _d->entries.end()) {
+                return it->second.value;
+            }
+        }
+        return V {};
+    }
+    auto _at(const K& k) const -> V&
+    {
+        if (!_d) {
+            _go_panic("assignment to entry in nil map");
+        }
+        auto [it, inserted] = _d->entries.try_emplace(k, _entry { V {}, _d->insertions });
+        if (inserted) {
+            _d->insertions++;
+        }
+        return it->second.value;
+    }
+    auto _comma_ok(const K& k) const -> std::tuple<V, bool>
+    {
+        if (_d) {
+            if (auto it = _d->entries.find(k); it != _d->entries.end()) {
+                return std::tuple { it->second.value, true };
+            }
+        }
+        return std::tuple { V {}, false };
+    }
+    void _delete(const K& k) const
+    {
+        if (_d) {
+            _d->entries.erase(k);
+        }
+    }
+    auto size() const -> std::size_t { return _d ? _d->entries.size() : 0; }
+    auto _keys() const -> std::shared_ptr<std::vector<K>>
+    {
+        auto keys = std::make_shared<std::vector<K>>();
+        if (!_d) {
+            return keys;
+        }
+        keys->reserve(_d->entries.size());
+        for (const auto& element : _d->entries) {
+            keys->push_back(element.first);
+        }
+        if constexpr (_go_map_iteration == _go_map_order::insertion) {
+            std::sort(keys->begin(), keys->end(), [this](const K& a, const K& b) { return _d->entries.at(a).inserted < _d->entries.at(b).inserted; });
+        } else if constexpr (_go_map_iteration == _go_map_order::random) {
+            if (!keys->empty()) {
+                static std::mt19937_64 generator { std::random_device {}() };
+                std::rotate(keys->begin(), keys->begin() + generator() % keys->size(), keys->end());
+            }
+        }
+        return keys;
+    }
+    auto begin() const -> iterator { return iterator { this, _keys(), 0 }; }
+    auto end() const -> iterator { return iterator { this, nullptr, size() }; }
+    auto operator==(std::nullptr_t) const -> bool { return !_d; }
+    auto _str() const -> std::string
+    {
+        std::vector<std::pair<K, V>> elements;
+        for (const auto& element : *this) {
+            elements.push_back(element);
+        }
+        if constexpr (requires(const K& a, const K& b) { a < b; }) {
+            std::sort(elements.begin(), elements.end(), [](const auto& a, const auto& b) { return a.first < b.first; });
+        }
+        std::stringstream ss;
+        ss << "map[";
+        for (std::size_t i = 0; i < elements.size(); i++) {
+            if (i > 0) {
+                ss << " ";
+            }
+            _format_output(ss, elements[i].first);
+            ss << ":";
+            _format_output(ss, elements[i].second);
+        }
+        ss << "]";
+        return ss.str();
+    }
+};
+`,
 	}
-	for k, v := range replacements {
-		if strings.Contains(output, k) {
+	// Functions that are used by functions further down in functionOrder
+	// are discovered by also searching the functions that have been added.
+	added := ""
+	for i := len(functionOrder) - 1; i >= 0; i-- {
+		k := functionOrder[i]
+		if strings.Contains(output, k) || strings.Contains(added, k) {
 			output = strings.Replace(output, k, strings.Replace(k, ".", "", -1), -1)
-			output = v + "\n" + output
+			added = replacements[k] + "\n" + added
 		}
 	}
-	return output
+	return added + output
 }
 
 // Name and type is used to keep a variable name and a variable type
@@ -334,12 +1854,12 @@ func FunctionArguments(source string) string {
 	// First find all names and all types
 	currentType := ""
 	currentName := ""
-	splitted := strings.Split(source, ",")
+	splitted := SplitArgs(source)
 	for i := len(splitted) - 1; i >= 0; i-- {
 		nameAndMaybeType := strings.TrimSpace(splitted[i])
 		if strings.Contains(nameAndMaybeType, " ") {
-			nameAndType := strings.Split(nameAndMaybeType, " ")
-			currentType = TypeReplace(strings.Join(nameAndType[1:], " "))
+			nameAndType := strings.SplitN(nameAndMaybeType, " ", 2)
+			currentType = TypeReplace(nameAndType[1])
 			currentName = nameAndType[0]
 		} else {
 			currentName = nameAndMaybeType
@@ -388,26 +1908,33 @@ func CPPTypes(args string) string {
 	return strings.Join(atypes, ", ")
 }
 
-// FunctionSignature transforms a function signature that spans one line
+// FunctionSignature transforms a function signature that spans one line,
+// or a function prototype if the line does not end with the body.
 // Will change the "func main" signature to a main function that returns an int.
 func FunctionSignature(source string) (output, returntype, name string) {
 	if len(strings.TrimSpace(source)) == 0 {
 		return source, "", ""
 	}
 	output = source
-	args := FunctionArguments(leftBetween(output, "(", ")"))
-	// Has return values in a parenthesis
-	var rets string
-	if strings.Contains(output, ") (") {
-		// There is a parenthesis with return types in the function signature
-		rets = FunctionRetvals(between(output, ")", "{", false, true))
-	} else {
-		// There is not a parenthesis with return types in the function signature
-		rets = FunctionRetvals(between(output, ")", "{", true, true))
-	}
-	if strings.Contains(rets, ",") {
+	paramsStart := strings.Index(output, "(")
+	paramsEnd := matchingBracket(output, paramsStart)
+	args := FunctionArguments(output[paramsStart+1 : paramsEnd])
+	// The return values may be in a parenthesis, and a function prototype has no body
+	bodyStart := strings.LastIndex(output, "{")
+	prototype := !strings.HasSuffix(output, "{")
+	if prototype {
+		bodyStart = len(output)
+	}
+	rets := strings.TrimSpace(output[paramsEnd+1 : bodyStart])
+	multiple := strings.HasPrefix(rets, "(") && len(SplitArgs(rets[1:len(rets)-1])) > 1
+	if strings.HasPrefix(rets, "(") {
+		rets = FunctionRetvals(rets)
+	}
+	if multiple {
 		// Multiple return
 		rets = tupleType + "<" + CPPTypes(rets) + ">"
+	} else {
+		rets = TypeReplace(rets)
 	}
 	name = leftBetween(output, "func ", "(")
 	if name == "main" {
@@ -417,37 +1944,37 @@ func FunctionSignature(source string) (output, returntype, name string) {
 		rets = "void"
 	}
 	output = "auto " + name + "(" + args + ") -> " + rets + " {"
+	if prototype {
+		output = "auto " + name + "(" + args + ") -> " + rets + ";"
+	}
 	return strings.TrimSpace(output), rets, name
 }
 
-// Split arguments. Handles quoting 1 level deep.
+// Split arguments. Handles quotes, escaped quotes and nested brackets.
 func SplitArgs(s string) []string {
 	inQuote := false
 	inSingleQuote := false
-	inPar := false
-	inCurly := false
+	escaped := false
+	depth := 0
 	var args []string
 	word := ""
 	for _, letter := range s {
-		switch letter {
-		case '"':
+		switch {
+		case escaped:
+			escaped = false
+		case letter == '\\' && (inQuote || inSingleQuote):
+			escaped = true
+		case letter == '"' && !inSingleQuote:
 			inQuote = !inQuote
-		case '\'':
+		case letter == '\'' && !inQuote:
 			inSingleQuote = !inSingleQuote
+		case inQuote || inSingleQuote:
+		case letter == '(' || letter == '{' || letter == '[':
+			depth++
+		case letter == ')' || letter == '}' || letter == ']':
+			depth--
 		}
-		if letter == '(' && !inQuote && !inSingleQuote && !inPar && !inCurly {
-			inPar = true
-		}
-		if letter == ')' && !inQuote && !inSingleQuote {
-			inPar = false
-		}
-		if letter == '{' && !inQuote && !inSingleQuote && !inPar && !inCurly {
-			inCurly = true
-		}
-		if letter == '}' && !inQuote && !inSingleQuote {
-			inCurly = false
-		}
-		if letter == ',' && !inQuote && !inSingleQuote && !inPar && !inCurly {
+		if letter == ',' && !inQuote && !inSingleQuote && depth == 0 {
 			args = append(args, strings.TrimSpace(word))
 			word = ""
 		} else {
@@ -643,49 +2170,197 @@ func DeferCall(source string) string {
 	trimmed := strings.TrimSpace(after("defer", source))
 
 	// This function handles three possibilities:
-	// * defer f()
-	// * defer func() { asdf }();
+	// * defer f(x)
+	// * defer func() { asdf }(), with or without parameters and arguments
 	// * "defer func() {" and then later "}()"
+	//
+	// The deferred calls are pushed to the defer stack of the function, together with the
+	// arguments, which are evaluated right away, and the name of the function that is
+	// called, which is the only function that can recover a panic. Function literals
+	// capture by value, since the variables that they capture are boxes.
+
+	deferCounter++
+	if strings.HasPrefix(trimmed, "func(") {
+		paramsEnd := matchingBracket(trimmed, len("func"))
+		params := FunctionArguments(trimmed[len("func("):paramsEnd])
+		lambda := "[=](" + params + ") {"
+		name := strconv.Quote(DeferName())
+		if strings.HasPrefix(trimmed, "func("+deferredFunction) {
+			// A function value, that FunctionVariables has evaluated when deferring
+			name = `""`
+		}
+		if strings.HasSuffix(trimmed, "{") {
+			// Anonymous function, on multiple lines
+			unfinishedDeferFunction = true // output "}, args);" later on, when "}(args)" is encountered in the Go code
+			return "// " + trimmed + "\n" + deferStack + ".push(" + name + ", " + lambda
+		}
+		// Anonymous function, on one line
+		argsStart := strings.LastIndex(trimmed, "}(")
+		body := strings.TrimSpace(trimmed[strings.Index(trimmed, "{")+1 : argsStart])
+		translated := strings.Replace(TranslateLines(body), recoverPlaceholder, RecoverCall(DeferName()), -1)
+		return "// " + trimmed + "\n" + deferStack + ".push(" + name + ", " + lambda + " " + translated + "; }" + DeferArguments(trimmed[argsStart+1:]) + ");"
+	}
+	// Assume a regular function call
+	argsStart := openingBracket(trimmed, len(trimmed)-1)
+	var params, names []string
+	for i, arg := range SplitArgs(trimmed[argsStart+1 : len(trimmed)-1]) {
+		if arg == "" {
+			continue
+		}
+		name := deferPrefix + "arg" + strconv.Itoa(i)
+		params = append(params, "auto "+name)
+		names = append(names, name)
+	}
+	translated := TranslateLines(trimmed[:argsStart] + "(" + strings.Join(names, ", ") + ")")
+	return "// " + trimmed + "\n" + deferStack + ".push(" + strconv.Quote(trimmed[:argsStart]) + ", [=](" + strings.Join(params, ", ") + ") { " + translated + "; }" + DeferArguments(trimmed[argsStart:]) + ");"
+}
+
+// DeferArguments transforms the arguments of a deferred call, like "(a, b)",
+// to the arguments that follow the function in the call to push, like ", a, b"
+func DeferArguments(args string) string {
+	args = strings.TrimSpace(args)
+	args = strings.TrimSpace(args[1 : len(args)-1])
+	if args == "" {
+		return ""
+	}
+	return ", " + args
+}
+
+// DeferName returns the name of the function literal of the last defer statement
+func DeferName() string {
+	return deferPrefix + strconv.Itoa(deferCounter)
+}
+
+// recoverPlaceholder is a call to recover() in a statement that is translated on its own,
+// until the function that it is called in is known
+const recoverPlaceholder = "_go_panic_recover()"
+
+// RecoverCall returns the call to recover() in the given function, which is "" in
+// function literals that are not deferred
+func RecoverCall(function string) string {
+	return "_go_panic_recover(" + strconv.Quote(function) + ")"
+}
+
+// BlockDefers checks if the block that starts at the first of the given lines contains a defer
+// statement. The defer statements in the function literals in the block are left out, since
+// the function literals have defer stacks of their own.
+func BlockDefers(lines []string) bool {
+	depth := 0
+	literalDepth := -1 // the depth of the function literal that the line is in, if any
+	for _, line := range lines {
+		trimmed := stripSingleLineComment(strings.TrimSpace(line))
+		if literalDepth == -1 {
+			if strings.HasPrefix(trimmed, "defer ") {
+				return true
+			}
+			if strings.Contains(trimmed, "func(") {
+				if _, literal := FunctionLiterals(trimmed, true); literal != nil {
+					literalDepth = depth
+				}
+			}
+		}
+		depth += countOutsideQuotes(trimmed, "{") - countOutsideQuotes(trimmed, "}")
+		if depth < 0 {
+			// The end of the block
+			break
+		}
+		if depth <= literalDepth {
+			literalDepth = -1
+		}
+	}
+	return false
+}
+
+// DeferReturn makes the deferred calls before returning the given value
+func DeferReturn(value, returnType string) string {
+	if value == "" {
+		return "{ " + deferStack + ".run(); return; }"
+	}
+	return "{ " + returnType + " _go_result = " + value + "; " + deferStack + ".run(); return _go_result; }"
+}
+
+// LeadingDeclaration checks if the given line is a variable declaration that can be placed
+// before the try block of a function that defers calls, like the declarations of named results
+func LeadingDeclaration(line string) bool {
+	return (strings.HasPrefix(line, "var ") || boxDeclarationRegexp.MatchString(line)) && !strings.HasSuffix(line, "{")
+}
+
+// PanicCatch makes the deferred calls at the end of a function, and ends the
+// try block that surrounds the body of a function that defers calls. When a
+// panic is caught, the deferred calls are made, and the panic continues unless
+// one of them called recover(). The function then returns the named results,
+// if there are any, or zero values.
+func PanicCatch(functionName, returnType, namedResults string) string {
+	s := deferStack + ".run();\n} catch (const _go_panic_error&) {\n" + deferStack + ".unwind();\n}\n"
+	if namedResults != "" {
+		s += "return " + ResultsValue(namedResults, returnType) + ";\n"
+	} else if functionName != "main" && returnType != "void" {
+		s += "return {};\n"
+	}
+	return s
+}
 
-	if strings.HasPrefix(trimmed, "func() {") && strings.HasSuffix(trimmed, "}()") {
-		// Anonymous function, on one line
-		deferCounter++
-		trimmed = strings.TrimSpace(leftBetween(trimmed, "func() {", "}()"))
-		// TODO: let go2cpp() return pure source code + includes to place at the top, not just one large string
-		return "// " + trimmed + "\nstd::shared_ptr<void> _defer" + strconv.Itoa(deferCounter) + "(nullptr, [](...) { " + go2cpp(trimmed) + "; });"
-	} else if trimmed == "func() {" {
-		// Anonymous function, on multiple lines
-		deferCounter++
-		unfinishedDeferFunction = true // output "});" later on, when "}()" is encountered in the Go code
-		return "// " + trimmed + "\nstd::shared_ptr<void> _defer" + strconv.Itoa(deferCounter) + "(nullptr, [](...) { "
-	} else {
-		// Assume a regular function call
-		return "// " + trimmed + "\nstd::shared_ptr<void> _defer" + strconv.Itoa(deferCounter) + "(nullptr, [](...) { " + trimmed + "; });"
+// ResultsValue returns the value of the given comma separated results, for the given return type
+func ResultsValue(results, returnType string) string {
+	if strings.HasPrefix(returnType, tupleType) {
+		return returnType + "{" + results + "}"
 	}
+	return results
+}
+
+// PanicMain renames the main function, and calls it from a new main function
+// that outputs unrecovered panics and exits with status 2, like Go does
+func PanicMain(source string) string {
+	return strings.Replace(source, "auto main() -> int {", "auto _go_main() -> int {", 1) + `
+auto main() -> int
+{
+    try {
+        return _go_main();
+    } catch (const _go_panic_error& e) {
+        return _go_panic_exit(e);
+    }
+}
+`
 }
 
 func IfSentence(source string) (output string) {
-	output = source
 	expression := strings.TrimSpace(leftBetweenRightmost(source, "if", "{"))
-	return "if (" + expression + ") {"
+	return "if (" + InitAndCondition(expression) + ") {"
 }
 
 func ElseIfSentence(source string) (output string) {
-	output = source
 	expression := strings.TrimSpace(leftBetweenRightmost(source, "} else if", "{"))
-	return "} else if (" + expression + ") {"
+	return "} else if (" + InitAndCondition(expression) + ") {"
+}
+
+// InitAndCondition transforms the expression of an if sentence, that may start
+// with an init statement, like "v, ok := m[k]; ok". The variables that are declared
+// by the init statement are then in scope in the following else if and else blocks,
+// like in Go, since C++ also has if sentences with init statements.
+func InitAndCondition(expression string) string {
+	parts := splitOutsideQuotes(expression, ';')
+	if len(parts) != 2 {
+		return expression
+	}
+	return InitStatement(parts[0]) + "; " + strings.TrimSpace(parts[1])
+}
+
+// InitStatement transforms the init statement of an if sentence or switch, like "x := f()"
+func InitStatement(statement string) string {
+	return strings.TrimSuffix(strings.TrimSpace(TranslateLines(strings.TrimSpace(statement))), ";")
 }
 
 func TypeReplace(source string) string {
-	// TODO: uintptr, complex64 and complex128
 	trimmed := strings.TrimSpace(source)
-	// For pointer types, move the star
+	// Pointer types are _go_ptr, which frees the values when they are no longer used
 	if strings.HasPrefix(trimmed, "*") {
-		trimmed = trimmed[1:] + "*"
+		return "_go_ptr<" + TypeReplace(trimmed[1:]) + ">"
 	}
 	switch trimmed {
 	case "string":
 		return "std::string"
+	case "error":
+		return "_go_error"
 	case "float64":
 		return "double"
 	case "float32":
@@ -710,105 +2385,466 @@ func TypeReplace(source string) string {
 		return "std::uint8_t"
 	case "rune":
 		return "std::int32_t"
+	case "int":
+		return "long long"
 	case "uint":
-		return "unsigned int"
+		return "unsigned long long"
+	case "uintptr":
+		return "std::uintptr_t"
+	case "complex128":
+		return "std::complex<double>"
+	case "complex64":
+		return "std::complex<float>"
+	case "interface{}", "any":
+		return "_go_any"
+	case "struct{}":
+		return "_go_struct"
 	default:
 		if strings.HasPrefix(trimmed, "[]") {
 			innerType := trimmed[2:]
-			return "std::vector<" + TypeReplace(innerType) + ">"
+			return "_go_slice<" + TypeReplace(innerType) + ">"
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			closing := matchingBracket(trimmed, 0)
+			return "std::array<" + TypeReplace(trimmed[closing+1:]) + ", " + trimmed[1:closing] + ">"
+		}
+		if strings.HasPrefix(trimmed, "func(") {
+			return FunctionType(trimmed)
+		}
+		for _, prefix := range []string{"chan<- ", "<-chan ", "chan "} {
+			if strings.HasPrefix(trimmed, prefix) {
+				return "_go_chan<" + TypeReplace(trimmed[len(prefix):]) + ">"
+			}
+		}
+		if strings.HasPrefix(trimmed, "iter.Seq[") || strings.HasPrefix(trimmed, "iter.Seq2[") {
+			// The function types of iterators, that call yield with each value
+			pos := strings.Index(trimmed, "[")
+			var params []string
+			for _, param := range SplitArgs(trimmed[pos+1 : len(trimmed)-1]) {
+				params = append(params, TypeReplace(param))
+			}
+			return "_go_func<void(_go_func<bool(" + strings.Join(params, ", ") + ")>)>"
+		}
+		if strings.HasPrefix(trimmed, "map[") {
+			keyType, valueType := MapTypes(trimmed)
+			return "_go_map<" + TypeReplace(keyType) + ", " + TypeReplace(valueType) + ">"
 		}
 		return trimmed
 	}
 }
 
-// TODO: Make sure all variations are covered:
-// * [_] for _ = range list {
-// * [_] for i := range list {
-// * [_] for i, v := range list {
-// * [_] for _, v := range list {
-// * [_] for i, _ := range list {
-// * [_] for _, _ = range list {
-// * [_] for _ = range map {
-// * [_] for k := range map {
-// * [_] for k, v := range map {
-// * [_] for _, v := range map {
-// * [_] for k, _ := range map {
-// * [_] for _, _ = range map {
-// * [_] for i := 0; i < 10; i++ {
-// * [_] for {
-// * [_] for ;; {
-// * not possible: for x := range 10 {
-func ForLoop(source string, encounteredHashMaps []string) string {
-	expression := strings.TrimSpace(leftBetween(source, "for", "{"))
-	if expression == "" {
-		// endless loop
-		return "for (;;) {"
+// FunctionType transforms a Go function type, like "func(int) string",
+// to a C++ function type, like "_go_func<std::string(int)>"
+func FunctionType(funcType string) string {
+	closing := matchingBracket(funcType, len("func"))
+	params := ParameterTypes(funcType[len("func("):closing])
+	for i, param := range params {
+		params[i] = TypeReplace(param)
+	}
+	return "_go_func<" + ResultType(funcType[closing+1:]) + "(" + strings.Join(params, ", ") + ")>"
+}
+
+// ParameterTypes returns the Go types of the given parameters, that may
+// be named, like "a, b int, s string", or not, like "int, int, string"
+func ParameterTypes(params string) []string {
+	var names, types []string
+	named := false
+	for _, param := range SplitArgs(params) {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		if fields := strings.SplitN(param, " ", 2); len(fields) == 2 && !strings.HasPrefix(param, "func(") && !strings.HasPrefix(param, "map[") && !strings.HasPrefix(param, "chan ") && !strings.HasPrefix(param, "<-chan ") {
+			named = true
+			for range names {
+				types = append(types, fields[1])
+			}
+			names = nil
+			types = append(types, fields[1])
+			continue
+		}
+		names = append(names, param)
 	}
-	// for range, with no comma
-	if strings.Count(expression, ",") == 0 && strings.Contains(expression, "range") {
-		fields := strings.Split(expression, " ")
-		varName := fields[0]
-		listName := fields[len(fields)-1]
-
-		// for i := range l {
-		// -->
-		// for (auto i = 0; i < std::size(l); i++) {
-
-		hashMapName := listName
-		if has(encounteredHashMaps, hashMapName) {
-			// looping over the key of a hash map, not over the index of a list
-			return "for (const auto & [" + varName + ", " + varName + "__" + "] : " + hashMapName + ") {"
-		} else if varName == "_" {
-			return "for (const auto & [" + varName + "__" + ", " + varName + "___" + "] : " + hashMapName + ") {"
+	if !named {
+		return names
+	}
+	return types
+}
+
+// ResultType transforms the results of a Go function, like "int" or
+// "(int, error)", to a C++ type, like "int" or "std::tuple<int, error>"
+func ResultType(results string) string {
+	results = strings.TrimSpace(results)
+	switch {
+	case results == "":
+		return "void"
+	case strings.HasPrefix(results, "("):
+		var types []string
+		for _, result := range SplitArgs(results[1 : len(results)-1]) {
+			types = append(types, TypeReplace(result))
+		}
+		if len(types) == 1 {
+			return types[0]
+		}
+		return tupleType + "<" + strings.Join(types, ", ") + ">"
+	default:
+		return TypeReplace(results)
+	}
+}
+
+// functionLiteral is a function literal that spans several lines
+type functionLiteral struct {
+	depth        int    // the curly bracket depth where the function literal ends
+	defers       bool   // the function literal defers calls, and has a defer stack of its own
+	returnType   string // the C++ return type
+	namedResults string // the named results, if the function literal defers calls
+}
+
+// literalFunctionName is the name of the function that a function literal on one line
+// that defers calls is translated as, to give it a defer stack of its own
+const literalFunctionName = "_go_literal"
+
+// FunctionLiterals transforms the function literals on the given line, like
+// "func(x int) int { return x * 2 }", to C++ lambdas. Inside of functions,
+// the lambdas capture by value, since the captured variables are boxes.
+// Returns the line, and the function literal that continues on the next lines, if any.
+func FunctionLiterals(line string, insideFunction bool) (string, *functionLiteral) {
+	capture := "[]"
+	if insideFunction {
+		capture = "[=]"
+	}
+	for pos := indexOutsideQuotes(line, "func(", 0); pos != -1; pos = indexOutsideQuotes(line, "func(", pos+1) {
+		if pos > 0 && isIdentifierLetter(line[pos-1]) {
+			continue
+		}
+		if lineStart := strings.LastIndex(line[:pos], "\n") + 1; indexOutsideQuotes(line[lineStart:pos], "//", 0) != -1 {
+			// In a comment
+			continue
+		}
+		paramsEnd := matchingBracket(line, pos+len("func"))
+		if paramsEnd == -1 {
+			break
+		}
+		bracePos := indexOutsideQuotes(line, "{", paramsEnd)
+		if bracePos == -1 {
+			break
+		}
+		results := line[paramsEnd+1 : bracePos]
+		if strings.ContainsAny(results, "=:;") || (strings.TrimSpace(results) != "" && !strings.HasPrefix(results, " ")) {
+			// A function type and not a function literal
+			continue
+		}
+		header := capture + "(" + FunctionArguments(line[pos+len("func("):paramsEnd]) + ")"
+		returnType := "void"
+		if strings.TrimSpace(results) != "" {
+			returnType = ResultType(results)
+			header += " -> " + returnType
+		}
+		braceClosing := matchingBracket(line, bracePos)
+		if braceClosing == -1 {
+			// The function literal continues on the next lines
+			return line[:pos] + header + " {", &functionLiteral{returnType: returnType}
+		}
+		var statements []string
+		defers := false
+		for _, statement := range splitOutsideQuotes(line[bracePos+1:braceClosing], ';') {
+			statement = strings.TrimSpace(statement)
+			defers = defers || strings.HasPrefix(statement, "defer ")
+			statements = append(statements, statement)
+		}
+		var body string
+		if defers {
+			// Translated like a function, which gets a defer stack of its own, and then the body is used
+			function := TranslateLines("func " + literalFunctionName + line[pos+len("func"):bracePos] + "{\n" + strings.Join(statements, "\n") + "\n}")
+			body = function[strings.Index(function, "{")+1 : strings.LastIndex(function, "}")]
 		} else {
-			// looping over the index of a list
-			return "for (std::size_t " + varName + " = 0; " + varName + " < std::size(" + listName + "); " + varName + "++) {"
+			body = strings.Replace(TranslateLines(strings.Join(statements, "\n")), recoverPlaceholder, RecoverCall(""), -1)
+		}
+		line = line[:pos] + header + " { " + strings.TrimSpace(body) + " }" + line[braceClosing+1:]
+	}
+	return line, nil
+}
+
+// MapTypes returns the key type and the value type of a Go map type,
+// for instance "string" and "[]int" for "map[string][]int".
+func MapTypes(mapType string) (string, string) {
+	pos := strings.Index(mapType, "[")
+	closing := matchingBracket(mapType, pos)
+	if pos == -1 || closing == -1 {
+		panic("go2cpp: unrecognized map type: " + mapType)
+	}
+	return strings.TrimSpace(mapType[pos+1 : closing]), strings.TrimSpace(mapType[closing+1:])
+}
+
+// isIdentifierLetter checks if the given byte can be part of an identifier
+func isIdentifierLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// isMapValueType checks if the given text, between the key type of a map and a "{", is the
+// value type of a map literal, like "int" or "func(int, int) int", and not the rest of a
+// function signature, like "int) " in "func f(m map[string]int) {"
+func isMapValueType(valueType string) bool {
+	if mapValueTypeRegexp.MatchString(valueType) {
+		return true
+	}
+	if !strings.HasPrefix(valueType, "func(") {
+		return false
+	}
+	closing := matchingBracket(valueType, len("func"))
+	if closing == -1 {
+		return false
+	}
+	results := valueType[closing+1:]
+	return strings.Count(results, "(") == strings.Count(results, ")")
+}
+
+// MapLiterals transforms all map literals that start and end on the given line,
+// like map[string]int{"a": 1}, to _go_map literals
+func MapLiterals(line string) string {
+	for pos := indexOutsideQuotes(line, "map[", 0); pos != -1; pos = indexOutsideQuotes(line, "map[", pos+1) {
+		if pos > 0 && isIdentifierLetter(line[pos-1]) {
+			continue
+		}
+		closing := matchingBracket(line, pos+3)
+		if closing == -1 {
+			break
+		}
+		bracePos := strings.Index(line[closing:], "{")
+		if bracePos == -1 {
+			break
+		}
+		bracePos += closing
+		valueType := line[closing+1 : bracePos]
+		if !isMapValueType(valueType) {
+			// Not a map literal, but a map type, for instance in a function signature
+			continue
+		}
+		braceClosing := matchingBracket(line, bracePos)
+		if braceClosing == -1 {
+			// The map literal continues on the next lines
+			break
+		}
+		mapType := TypeReplace(line[pos:bracePos])
+		var elements []string
+		for _, pair := range SplitArgs(line[bracePos+1 : braceClosing]) {
+			if pair == "" {
+				continue
+			}
+			colon := indexOutsideQuotes(pair, ":", 0)
+			if colon == -1 {
+				panic("go2cpp: expected a key and a value in map literal: " + pair)
+			}
+			key := strings.TrimSpace(pair[:colon])
+			value := MapLiterals(strings.TrimSpace(pair[colon+1:]))
+			elements = append(elements, "{ "+key+", "+value+" }")
+		}
+		literal := mapType + "::make()"
+		if len(elements) > 0 {
+			literal = mapType + "{ " + strings.Join(elements, ", ") + " }"
+		}
+		line = line[:pos] + literal + line[braceClosing+1:]
+	}
+	return line
+}
+
+// ArrayLiterals transforms all array and slice literals that start and end on the given line,
+// like [2]int{1, 2} or [...]string{"a", "b"}, to std::array literals, and []int{1, 2} to _go_slice literals
+func ArrayLiterals(line string) string {
+	for pos := indexOutsideQuotes(line, "[", 0); pos != -1; pos = indexOutsideQuotes(line, "[", pos+1) {
+		if pos > 0 && (isIdentifierLetter(line[pos-1]) || line[pos-1] == ']' || line[pos-1] == ')') {
+			// Indexing, or part of a longer type
+			continue
+		}
+		m := arrayLiteralRegexp.FindStringSubmatch(line[pos:])
+		if m == nil {
+			continue
+		}
+		bracePos := pos + len(m[0]) - 1
+		braceClosing := matchingBracket(line, bracePos)
+		if braceClosing == -1 {
+			break
+		}
+		elements := ArrayLiterals(line[bracePos+1 : braceClosing])
+		size := m[1]
+		if size == "..." {
+			n := 0
+			for _, element := range SplitArgs(elements) {
+				if element != "" {
+					n++
+				}
+			}
+			size = strconv.Itoa(n)
 		}
+		literal := TypeReplace("["+size+"]"+m[2]) + "{ {" + elements + "} }"
+		if size == "" && strings.TrimSpace(elements) == "" {
+			// An empty slice, which is not nil
+			literal = TypeReplace("[]"+m[2]) + "::make(0)"
+		} else if size == "" {
+			literal = TypeReplace("[]"+m[2]) + "{" + elements + "}"
+		}
+		line = line[:pos] + literal + line[braceClosing+1:]
 	}
-	// for range, over index and element, or key and value
-	if strings.Count(expression, ",") == 1 && strings.Contains(expression, "range") && strings.Contains(expression, ":=") {
-		fields := strings.Split(expression, ":=")
-		varnames := strings.Split(fields[0], ",")
+	return line
+}
 
-		indexvar := varnames[0]
-		elemvar := varnames[1]
+// MakeSlice transforms make([]T, n) and make([]T, n, c) to _go_slice<T>::make(n, c)
+func MakeSlice(line string) string {
+	for pos := indexOutsideQuotes(line, "make([]", 0); pos != -1; pos = indexOutsideQuotes(line, "make([]", pos+1) {
+		closing := matchingBracket(line, pos+4)
+		if closing == -1 {
+			break
+		}
+		args := SplitArgs(line[pos+5 : closing])
+		line = line[:pos] + TypeReplace(args[0]) + "::make(" + strings.Join(args[1:], ", ") + ")" + line[closing+1:]
+	}
+	return line
+}
 
-		fields = strings.Split(expression, " ")
-		listName := fields[len(fields)-1]
-		hashMapName := listName
+// typeArguments are the functions that are called with a type as one of the
+// arguments, in the rewritten Go code, and the position of that argument
+var typeArguments = map[string]int{
+	"_go_assert":    1, // _go_assert(x, T), from x.(T)
+	"_go_assert_ok": 1, // _go_assert_ok(x, T), from v, ok := x.(T)
+	"_go_box":       0, // _go_box(T) or _go_box(T, value), for variables that are captured by function literals
+	"_go_chan_make": 0, // _go_chan_make(T) or _go_chan_make(T, n), from make(chan T, n)
+	"_go_convert":   0, // _go_convert(T, x), from T(x) for the numeric types
+	"_go_complex":   0, // _go_complex(T, re, im), from complex(re, im) and complex constants
+	"_go_new":       0, // _go_new(T) or _go_new(T, value), from new(T) and &T{...}
+}
 
-		if has(encounteredHashMaps, hashMapName) {
-			if indexvar == "_" {
-				// looping over the values of a hash map
-				hashMapHashKey := hashMapName + hashMapSuffix
-				return "for (const auto & " + hashMapHashKey + " : " + hashMapName + ") {" + "\n" + "auto " + elemvar + " = " + hashMapHashKey + ".second"
+// TypeArguments transforms calls like _go_assert(x, T) to _go_assert<T>(x),
+// for the functions in typeArguments
+func TypeArguments(line string) string {
+	for name, typePos := range typeArguments {
+		name += "("
+		for pos := indexOutsideQuotes(line, name, 0); pos != -1; pos = indexOutsideQuotes(line, name, pos+1) {
+			if pos > 0 && isIdentifierLetter(line[pos-1]) {
+				continue
+			}
+			closing := matchingBracket(line, pos+len(name)-1)
+			if closing == -1 {
+				break
+			}
+			args := SplitArgs(line[pos+len(name) : closing])
+			if len(args) <= typePos {
+				continue
 			}
-			// for k, v := range m
-			keyvar := indexvar
-			//hashMapHashKey := keyvar + hashMapSuffix + keysSuffix
-			return "for (const auto & [" + keyvar + ", " + elemvar + "] : " + hashMapName + ") {"
-			//return "for (auto " + hashMapHashKey + " : " + hashMapName + keysSuffix + ") {" + "\n" + "auto " + keyvar + " = " + hashMapHashKey + ".second;\nauto " + elemvar + " = " + hashMapName + ".at(" + hashMapHashKey + ".first)"
+			typeArg := TypeReplace(args[typePos])
+			args = append(args[:typePos], args[typePos+1:]...)
+			line = line[:pos] + name[:len(name)-1] + "<" + typeArg + ">(" + strings.Join(args, ", ") + ")" + line[closing+1:]
 		}
+	}
+	return line
+}
 
-		if indexvar == "_" {
-			return "for (auto " + elemvar + " : " + listName + ") {"
+// MakeMap transforms make(map[K]V) and make(map[K]V, hint) to _go_map<K, V>::make(hint)
+func MakeMap(line string) string {
+	for pos := indexOutsideQuotes(line, "make(map[", 0); pos != -1; pos = indexOutsideQuotes(line, "make(map[", pos+1) {
+		closing := matchingBracket(line, pos+4)
+		if closing == -1 {
+			break
+		}
+		args := SplitArgs(line[pos+5 : closing])
+		hint := ""
+		if len(args) > 1 {
+			hint = args[1]
 		}
-		return "for (std::size_t " + indexvar + " = 0; " + indexvar + " < std::size(" + listName + "); " + indexvar + "++) {" + "\n" + "auto " + elemvar + " = " + listName + "[" + indexvar + "]"
+		line = line[:pos] + TypeReplace(args[0]) + "::make(" + hint + ")" + line[closing+1:]
 	}
-	// not "for" + "range"
-	if strings.Contains(expression, ":=") {
-		if strings.HasPrefix(expression, "_,") && strings.Contains(expression, "range") {
-			// For each, no index
-			varname := leftBetween(expression, ",", ":")
-			fields := strings.SplitN(expression, "range ", 2)
-			listname := fields[1]
-			// C++11 and later for each loop
-			expression = "auto &" + varname + " : " + listname
-		} else {
-			expression = "auto " + strings.Replace(expression, ":=", "=", 1)
+	return line
+}
+
+// IndexReference transforms x[i] to _go_ref(x, i), for expressions that are
+// assigned to, since reading from a map must not insert the key.
+func IndexReference(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if !strings.HasSuffix(expression, "]") {
+		return expression
+	}
+	pos := openingBracket(expression, len(expression)-1)
+	if pos < 1 {
+		return expression
+	}
+	return "_go_ref(" + expression[:pos] + ", " + expression[pos+1:len(expression)-1] + ")"
+}
+
+// CommaOk transforms m[k] to m._comma_ok(k), for "v, ok := m[k]"
+func CommaOk(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if !strings.HasSuffix(expression, "]") {
+		return expression
+	}
+	pos := openingBracket(expression, len(expression)-1)
+	if pos < 1 {
+		return expression
+	}
+	return expression[:pos] + "._comma_ok(" + expression[pos+1:len(expression)-1] + ")"
+}
+
+// ForLoop transforms the start of a for loop, that may be an endless loop,
+// a loop with a condition, a loop with init and post statements, or a range loop
+func ForLoop(source string) string {
+	source = strings.TrimSpace(source)
+	expression := strings.TrimSpace(source[len("for"):blockBrace(source)])
+	if expression == "" {
+		// endless loop
+		return "for (;;) {"
+	}
+	if parts := splitOutsideQuotes(expression, ';'); len(parts) == 3 {
+		// for init; condition; post
+		init, post := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[2])
+		if init != "" {
+			init = InitStatement(init)
+		}
+		if post != "" {
+			post = InitStatement(post)
+		}
+		return "for (" + init + "; " + strings.TrimSpace(parts[1]) + "; " + post + ") {"
+	}
+	if strings.HasPrefix(expression, "range ") {
+		// for range, without variables
+		rangeExpression := strings.TrimSpace(expression[len("range "):])
+		return "for ([[maybe_unused]] auto&& _go_unused : _go_range_keys(" + rangeExpression + ")) {"
+	}
+	if m := rangeLoopRegexp.FindStringSubmatch(expression); m != nil {
+		// for range, over index and element, or key and value.
+		// _go_range decides which, depending on the type of the expression.
+		varNames := strings.Split(m[1], ",")
+		keyName := strings.TrimSpace(varNames[0])
+		valueName := ""
+		if len(varNames) > 1 {
+			valueName = strings.TrimSpace(varNames[1])
+		}
+		rangeExpression := strings.TrimSpace(m[3])
+		if m[2] == "=" {
+			// for k, v = range assigns to existing variables
+			rangeFunction := "_go_range("
+			if valueName == "" {
+				rangeFunction = "_go_range_keys("
+			}
+			output := "for (auto&& [_go_range_key, _go_range_value] : " + rangeFunction + rangeExpression + ")) {"
+			if keyName != "_" {
+				output += "\n" + keyName + " = _go_range_key;"
+			}
+			if valueName != "" && valueName != "_" {
+				output += "\n" + valueName + " = _go_range_value;"
+			}
+			return output
+		}
+		rangeFunction := "_go_range("
+		if valueName == "" {
+			// With one variable, a pointer to an array is not dereferenced
+			rangeFunction = "_go_range_keys("
+		}
+		if valueName == "" || valueName == keyName {
+			// for k := range, or for _, _ := range
+			valueName = keyName + "__"
 		}
+		return "for (auto [" + keyName + ", " + valueName + "] : " + rangeFunction + rangeExpression + ")) {"
 	}
-	return "for (" + expression + ") {"
+	// for condition
+	return "for (; " + expression + "; ) {"
 }
 
 func SwitchExpressionVariable() string {
@@ -819,26 +2855,97 @@ func LabelName() string {
 	return labelPrefix + strconv.Itoa(labelCounter)
 }
 
-func Switch(source string) (output string) {
-	output = strings.TrimSpace(source)[len("switch "):]
-	if strings.HasSuffix(output, "{") {
-		output = strings.TrimSpace(output[:len(output)-1])
+// breakable is a for loop or a switch, that a break statement may end
+type breakable struct {
+	depth      int    // the curly bracket depth that the statement is at
+	isSwitch   bool   // a switch, and not a for loop
+	tag        string // the variable that holds the value that the switch is on, if it is on a value
+	firstCase  bool   // the next case is the first one
+	breakLabel string // the label after the statement, if a break statement ends it with a goto
+	label      string // the Go label of the statement, if it has one
+
+	// The label at the end of the loop body, if a continue statement with a label continues the loop
+	continueLabel string
+}
+
+// findBreakable returns the for loop or switch with the given Go label, or nil
+func findBreakable(label string) *breakable {
+	for i := len(breakables) - 1; i >= 0; i-- {
+		if breakables[i].label == label {
+			return breakables[i]
+		}
+	}
+	return nil
+}
+
+// BranchToLabel transforms a break or continue statement with a label, like "break outer",
+// to a goto. C++ has no labeled break and continue, so a break jumps to a label after the
+// loop or switch, and a continue jumps to a label at the end of the loop body.
+func BranchToLabel(statement string) string {
+	fields := strings.Fields(statement)
+	b := findBreakable(fields[1])
+	if b == nil {
+		panic("go2cpp: " + fields[0] + " to a label that is not on an enclosing loop or switch: " + statement)
+	}
+	if fields[0] == "continue" {
+		if b.continueLabel == "" {
+			b.continueLabel = LabelName()
+			labelCounter++
+		}
+		return "goto " + b.continueLabel + "; // " + statement
+	}
+	if b.breakLabel == "" {
+		b.breakLabel = LabelName()
+		labelCounter++
+	}
+	return "goto " + b.breakLabel + "; // " + statement
+}
+
+// Switch transforms the start of a switch, that may have an init statement
+// and a value that it is on. The switch is a block, so that the variables
+// that are declared by the init statement are in scope in the cases only.
+func Switch(source string) (string, *breakable) {
+	header := strings.TrimSpace(strings.TrimSpace(source)[len("switch"):])
+	header = strings.TrimSpace(strings.TrimSuffix(header, "{"))
+	init, tag := "", header
+	if parts := splitOutsideQuotes(header, ';'); len(parts) == 2 {
+		init, tag = InitStatement(parts[0]), strings.TrimSpace(parts[1])
 	}
 	switchExpressionCounter++
-	firstCase = true
-	return "auto&& " + SwitchExpressionVariable() + " = " + output + "; // switch on " + output
+	b := &breakable{isSwitch: true, firstCase: true}
+	output := "{ // switch"
+	if init != "" {
+		output += "\n" + init + ";"
+	}
+	if tag != "" {
+		b.tag = SwitchExpressionVariable()
+		output += "\nauto&& " + b.tag + " = " + tag + "; // switch on " + tag
+	}
+	return output, b
 }
 
-func Case(source string) (output string) {
-	output = source
-	s := leftBetween(output, " ", ":")
-	if firstCase {
-		firstCase = false
+// Case transforms a case of the given switch, which may have several values,
+// or conditions if the switch is not on a value
+func Case(source string, b *breakable) (output string) {
+	values := SplitArgs(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(source), "case "), ":"))
+	var conditions []string
+	for _, value := range values {
+		switch {
+		case b.tag != "":
+			conditions = append(conditions, b.tag+" == "+value)
+		case len(values) > 1:
+			conditions = append(conditions, "("+value+")")
+		default:
+			conditions = append(conditions, value)
+		}
+	}
+	if b.firstCase {
+		b.firstCase = false
 		output = "if ("
 	} else {
 		output = "} else if ("
 	}
-	output += SwitchExpressionVariable() + " == " + s + ") { // case " + s
+	output += strings.Join(conditions, " || ") + ") { // case " + strings.Join(values, ", ")
 	if switchLabel != "" {
 		output += "\n" + switchLabel + ":"
 		switchLabel = ""
@@ -846,6 +2953,16 @@ func Case(source string) (output string) {
 	return output
 }
 
+// innermostSwitch returns the switch that the current line is directly within
+func innermostSwitch() *breakable {
+	for i := len(breakables) - 1; i >= 0; i-- {
+		if breakables[i].isSwitch {
+			return breakables[i]
+		}
+	}
+	panic("case outside of switch")
+}
+
 // Return transformed line and the variable names
 func VarDeclarations(source string) (string, []string) {
 
@@ -859,10 +2976,22 @@ func VarDeclarations(source string) (string, []string) {
 		if fields[0] == "var" {
 			fields = fields[1:]
 		}
+		if names := strings.Split(strings.Join(fields, ""), ","); len(names) > 1 && len(names) == len(fields) {
+			// Several variables without a type, like: var c, d = pair()
+			values := SplitArgs(right)
+			if len(values) == 1 {
+				return "auto [" + strings.Join(names, ", ") + "] = " + right, names
+			}
+			var declarations []string
+			for i, name := range names {
+				declarations = append(declarations, "auto "+name+" = "+values[i])
+			}
+			return strings.Join(declarations, ";"), names
+		}
 		if len(fields) == 2 {
 			return TypeReplace(fields[1]) + " " + fields[0] + " = " + right, []string{fields[0]}
 		} else if len(fields) > 2 {
-			if strings.Contains(source, ",") {
+			if strings.HasSuffix(fields[0], ",") {
 				leftFields := strings.Fields(left)
 				if leftFields[0] == "var" {
 					leftFields = leftFields[1:]
@@ -892,7 +3021,8 @@ func VarDeclarations(source string) (string, []string) {
 				}
 				return sb.String(), varNames
 			}
-			return TypeReplace(fields[1]) + " " + fields[0] + " " + strings.Join(fields[2:], " ") + " = " + right, []string{fields[0]}
+			// A type that contains spaces, like: var f func(x int) int = add
+			return TypeReplace(strings.Join(fields[1:], " ")) + " " + fields[0] + " = " + right, []string{fields[0]}
 		}
 		leftFields := strings.Fields(left)
 		if leftFields[0] == "var" {
@@ -948,8 +3078,20 @@ func VarDeclarations(source string) (string, []string) {
 	if fields[0] == "var" {
 		fields = fields[1:]
 	}
+	if len(fields) == 1 {
+		// An embedded field, which is named after its type. The type is qualified, since a
+		// field may not change what the name of a type means in the struct in C++.
+		name := strings.TrimPrefix(fields[0], "*")
+		return TypeReplace(strings.TrimSuffix(fields[0], name)+"::"+name) + " " + name + "{};", []string{name}
+	}
+	// The variables without a value are value-initialized with {}, which gives the zero value
+	// in Go for all the types, and for all the fields of structs, since they are also declared here
 	if len(fields) == 2 {
-		return TypeReplace(fields[1]) + " " + fields[0], []string{fields[0]}
+		return TypeReplace(fields[1]) + " " + fields[0] + "{};", []string{fields[0]}
+	}
+	if len(fields) > 2 && !strings.HasSuffix(fields[0], ",") {
+		// A type that contains spaces, like: var f func(int) int
+		return TypeReplace(strings.Join(fields[1:], " ")) + " " + fields[0] + "{};", []string{fields[0]}
 	}
 	if strings.Contains(source, ",") {
 		// Comma separated variable names, with one common variable type,
@@ -959,14 +3101,11 @@ func VarDeclarations(source string) (string, []string) {
 		var sb strings.Builder
 		var varNames []string
 
-		for i, varName := range fields[:lastIndex] {
-			if i > 0 {
-				sb.WriteString(";")
-			}
+		for _, varName := range fields[:lastIndex] {
 			if strings.HasSuffix(varName, ",") {
 				varName = varName[:len(varName)-1]
 			}
-			sb.WriteString(TypeReplace(varType) + " " + varName)
+			sb.WriteString(TypeReplace(varType) + " " + varName + "{};")
 			varNames = append(varNames, varName)
 		}
 
@@ -981,59 +3120,53 @@ func VarDeclarations(source string) (string, []string) {
 // TypeDeclaration returns a transformed string (from Go to C++),
 // and a bool if a struct is opened (with {).
 func TypeDeclaration(source string) (string, bool) {
-	fields := strings.Split(strings.TrimSpace(source), " ")
-	if fields[0] == "type" {
-		fields = fields[1:]
+	// Only the name is split from the type, since the type may contain spaces, like "func(int, int) int"
+	fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(source), "type ")), " ", 2)
+	if len(fields) != 2 {
+		// Unrecognized
+		panic("Unrecognized type declaration: " + source)
 	}
-	left := strings.TrimSpace(fields[0])
+	name := fields[0]
 	right := strings.TrimSpace(fields[1])
-	words := strings.Split(left, " ")
-	if len(fields) == 2 {
-		// Type alias
-		return "using " + left + " = " + TypeReplace(right), false
-	} else if len(words) == 2 {
-		// Type alias
-		return "using " + words[1] + " " + words[0] + " = " + TypeReplace(right), false
-	} else if strings.Contains(right, "struct") {
+	if strings.HasPrefix(right, "=") {
+		// type A = B
+		right = strings.TrimSpace(right[1:])
+	}
+	if strings.HasPrefix(right, "struct") && strings.HasSuffix(right, "{") {
 		// type Vec3 struct {
 		// to
 		// class Vec3 { public:
 		// also the closing bracket must end with a semicolon
-		return "class " + left + " { public:", true
-	} else if len(words) == 1 {
-		// Type alias
-		return "using " + left + " = " + TypeReplace(right), false
+		return "class " + name + " { public:", true
 	}
-	// Unrecognized
-	panic("Unrecognized type declaration: " + source)
+	// Type alias
+	return "using " + name + " = " + TypeReplace(right), false
 }
 
+// ConstDeclaration transforms a constant declaration, with the value that Constants has
+// given each constant, to a constexpr declaration in C++
 func ConstDeclaration(source string) (output string) {
-	output = source
-	fields := strings.SplitN(source, "=", 2)
-	if len(fields) == 0 {
-		panic("no fields in const declaration")
-	} else if len(fields) == 1 {
-		// This happens if there is only a constant name, with no value assigned
-		// Only simple iota incrementation is supported so far (not .. << ..)
-		iotaNumber++
-		return "const auto " + strings.TrimSpace(fields[0]) + " = " + strconv.Itoa(iotaNumber)
-	}
-	left := strings.TrimSpace(fields[0])
-	right := strings.TrimSpace(fields[1])
-	words := strings.Split(left, " ")
-	if right == "iota" {
-		iotaNumber = 0
-		right = strconv.Itoa(iotaNumber)
+	fields := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(source), "const "), "=", 2)
+	if len(fields) != 2 {
+		panic("go2cpp: const declaration without a value: " + source)
 	}
+	words := strings.Fields(fields[0])
+	right := strings.TrimSpace(fields[1])
 	if len(words) == 1 {
-		// No type
-		return "const auto " + left + " = " + right
+		// No type, so the type is the default type of the value
+		if strings.HasPrefix(right, "\"") || strings.HasPrefix(right, "`") {
+			return "const std::string " + words[0] + " = " + right
+		} else if _, err := strconv.ParseInt(right, 0, 64); err == nil {
+			return "constexpr " + TypeReplace("int") + " " + words[0] + " = " + right
+		}
+		return "constexpr auto " + words[0] + " = " + right
 	} else if len(words) == 2 {
-		if words[0] == "const" {
-			return "const auto " + words[1] + " = " + right
+		cppType := TypeReplace(words[1])
+		if cppType == "std::string" {
+			// std::string can not be constexpr before C++20
+			return "const std::string " + words[0] + " = " + right
 		}
-		return "const " + TypeReplace(words[1]) + " " + words[0] + " = " + right
+		return "constexpr " + cppType + " " + words[0] + " = " + right
 	}
 	// Unrecognized
 	panic("go2cpp: unrecognized const expression: " + source)
@@ -1056,7 +3189,7 @@ func HashElements(source, keyType string, keyForBoth bool) string {
 		if len(pairElements) != 2 {
 			panic("This should be two elements, separated by a colon and a space " + source)
 		}
-		return "{ " + strings.TrimSpace(pairElements[0]) + ", " + strings.TrimSpace(pairElements[1]) + " }, "
+		return "{ " + strings.TrimSpace(pairElements[0]) + ", " + strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(pairElements[1]), ",")) + " }, "
 	}
 	// Multiple pairs
 	pairs := strings.Split(source, ",")
@@ -1082,24 +3215,99 @@ func HashElements(source, keyType string, keyForBoth bool) string {
 func CreateStrMethod(varNames []string) string {
 	var sb strings.Builder
 	sb.WriteString("std::string _str() {\n")
-	sb.WriteString("  std::stringstream ss;\n")
-	sb.WriteString("  ss << \"{\";\n")
+	sb.WriteString("  std::stringstream _ss;\n")
+	sb.WriteString("  _ss << \"{\";\n")
 	for i, varName := range varNames {
 		if i > 0 {
-			sb.WriteString("  ss << \" \";\n")
+			sb.WriteString("  _ss << \" \";\n")
 		}
-		sb.WriteString("  _format_output(ss, ")
+		sb.WriteString("  _format_output(_ss, ")
 		sb.WriteString(varName)
 		sb.WriteString(");\n")
 	}
-	sb.WriteString("  ss << \"}\";")
-	sb.WriteString("  return ss.str();\n")
+	sb.WriteString("  _ss << \"}\";")
+	sb.WriteString("  return _ss.str();\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// CreateHashMethod creates an equality operator and a _hash() method,
+// so that the struct can be used as a key in a map
+func CreateHashMethod(structName string, varNames []string) string {
+	var sb strings.Builder
+	sb.WriteString("auto operator==(const " + structName + "&) const -> bool = default;\n")
+	sb.WriteString("auto _hash() const -> std::size_t {\n")
+	sb.WriteString("  std::size_t _h = 0;\n")
+	for _, varName := range varNames {
+		sb.WriteString("  _h = _go_hash_combine(_h, _go_hash<decltype(" + varName + ")> {}(" + varName + "));\n")
+	}
+	sb.WriteString("  return _h;\n")
 	sb.WriteString("}\n")
 	return sb.String()
 }
 
+// FieldType returns the Go type of a struct field declaration, like "X, Y int"
+func FieldType(source string) string {
+	fields := strings.Fields(source)
+	if len(fields) == 1 {
+		// Embedded field
+		return fields[0]
+	}
+	i := 0
+	for i < len(fields)-1 && strings.HasSuffix(fields[i], ",") {
+		i++
+	}
+	return strings.Join(fields[i+1:], " ")
+}
+
+// ComparableType checks if values of the given Go type can be compared with ==
+// in the generated C++ code. Slices, maps and functions can not, and neither can
+// interface values, since _go_any can not compare the values it holds.
+func ComparableType(goType string) bool {
+	goType = strings.TrimSpace(goType)
+	switch {
+	case strings.HasPrefix(goType, "[]"), strings.HasPrefix(goType, "map["), strings.HasPrefix(goType, "func"):
+		return false
+	case goType == "interface{}", goType == "any":
+		return false
+	case strings.HasPrefix(goType, "["):
+		return ComparableType(goType[matchingBracket(goType, 0)+1:])
+	}
+	for _, fieldType := range structFieldTypes[goType] {
+		if !ComparableType(fieldType) {
+			return false
+		}
+	}
+	return true
+}
+
 func go2cpp(source string) string {
+	CheckErrors(source)
+	if explainEscapes {
+		ExplainEscapes(source)
+	}
+	output := TranslateLines(Declarations(RuntimeChecks(Conversions(Pointers(Closures(StackAllocations(NamedResults(RangeFunctions(Channels(Variadic(Methods(FunctionVariables(Selectors(CompositeLiterals(Complex(Literals(Integers(Initialization(Constants(Identifiers(source)))))))))))))))))))))
+
+	// The order matters
+	output = LiteralStrings(output)
+	output = WholeProgramReplace(output)
+
+	// The order matters
+	output = AddFunctions(output)
+	if strings.Contains(output, "_go_panic_error") {
+		output = PanicMain(output)
+	}
+	output = AddIncludes(output)
+
+	return output
+}
+
+// TranslateLines converts Go source code to C++20, line by line, but does not
+// add the includes and functions that the generated code depends on.
+func TranslateLines(source string) string {
 	functionVarMap := map[string]string{} // variable names encountered in the function so far, and their corresponding smart names
+	deferredLiteral := ""                 // the name of the function literal of the defer statement that is being translated
+	deferredLiteralCount := 0             // the number of function literals that the defer statement is in
 	inMultilineString := false
 	debugOutput := false
 	lines := []string{}
@@ -1112,15 +3320,19 @@ func go2cpp(source string) string {
 	inHashMap := false
 	hashKeyType := ""
 	curlyCount := 0
-	// Keep track of encountered hash maps
-	// TODO: Use reflection instead to loop either one way or the other. The hash map may be defined in another package.
-	encounteredHashMaps := []string{}
 	// Keep track of encountered struct names
 	encounteredStructNames := []string{}
 	inStruct := false
-	usePrettyPrint := false
+	currentStructName := ""
+	structNames := []string{} // the structs that are declared at the top level
 	closingBracketNeedsASemicolon := false
-	for _, line := range strings.Split(source, "\n") {
+	functionCatchesPanics := false
+	namedResults := ""                       // the named results of the current function, if it defers calls
+	tryPending := false                      // the try block of the current function has not been started yet
+	functionLiterals := []*functionLiteral{} // the function literals that span several lines, that the line is in
+	label, nextLabel := "", ""               // the Go labels of the statements on this line and on the next line
+	sourceLines := strings.Split(source, "\n")
+	for i, line := range sourceLines {
 
 		if debugOutput {
 			fmt.Fprintf(os.Stderr, "%s\n", line)
@@ -1142,20 +3354,30 @@ func go2cpp(source string) string {
 			lines = append(lines, newLine)
 			continue
 		}
+		// The try block of a function that defers calls starts after the variable declarations
+		// at the start of the function, so that the named results can be returned after a panic
+		if tryPending && !LeadingDeclaration(trimmedLine) {
+			lines = append(lines, "try {")
+			tryPending = false
+		}
+		// The label of the statement on this line, if the previous line is a label
+		label, nextLabel = nextLabel, ""
 		// Keep track of how deep we are into curly brackets
-		curlyCount += (strings.Count(trimmedLine, "{") - strings.Count(trimmedLine, "}"))
+		if !inMultilineString {
+			curlyCount += countOutsideQuotes(trimmedLine, "{") - countOutsideQuotes(trimmedLine, "}")
+		}
 		if inImport && strings.Contains(trimmedLine, ")") {
 			inImport = false
 			continue
 		} else if inImport {
 			continue
-		} else if inVar && strings.Contains(trimmedLine, ")") {
+		} else if inVar && trimmedLine == ")" {
 			inVar = false
 			continue
 		} else if inType && strings.Contains(trimmedLine, ")") {
 			inType = false
 			continue
-		} else if inConst && strings.Contains(trimmedLine, ")") {
+		} else if inConst && trimmedLine == ")" {
 			inConst = false
 			continue
 		} else if inHashMap && trimmedLine == "}" {
@@ -1169,8 +3391,9 @@ func go2cpp(source string) string {
 			}
 
 			if inStruct {
-				// Gathering variable names from this struct
+				// Gathering variable names and types from this struct
 				encounteredStructNames = append(encounteredStructNames, varNames...)
+				structFieldTypes[currentStructName] = append(structFieldTypes[currentStructName], FieldType(trimmedLine))
 			}
 		} else if inType {
 			prevInStruct := inStruct
@@ -1178,47 +3401,136 @@ func go2cpp(source string) string {
 			if !prevInStruct && inStruct {
 				// Entering struct, reset the slice that is used to gather variable names
 				encounteredStructNames = []string{}
+				currentStructName = strings.Fields(trimmedLine)[0]
+				if curlyCount == 1 {
+					structNames = append(structNames, currentStructName)
+				}
 			}
 		} else if inConst {
 			newLine = ConstDeclaration(line)
 		} else if inHashMap && !inMultilineString {
 			newLine = HashElements(trimmedLine, hashKeyType, false)
-		} else if strings.HasPrefix(trimmedLine, "func") {
+		} else if strings.HasPrefix(trimmedLine, "func ") && !strings.HasSuffix(trimmedLine, "{") && !strings.HasSuffix(trimmedLine, "}") {
+			// A function without a body, that Declarations has declared
+			newLine, _, _ = FunctionSignature(trimmedLine)
+		} else if strings.HasPrefix(trimmedLine, "func ") {
 			functionVarMap = map[string]string{}
 			newLine, currentReturnType, currentFunctionName = FunctionSignature(trimmedLine)
-		} else if strings.HasPrefix(trimmedLine, "for") {
-			newLine = ForLoop(line, encounteredHashMaps)
-		} else if strings.HasPrefix(trimmedLine, "switch") {
-			newLine = Switch(line)
-		} else if strings.HasPrefix(trimmedLine, "case") {
-			newLine = Case(line)
+			namedResults = ""
+			// Functions that defer calls must catch panics, in case a deferred call recovers
+			functionCatchesPanics = strings.HasPrefix(line, "func") && BlockDefers(sourceLines[i+1:])
+			if functionCatchesPanics {
+				newLine += "\n_go_defer_stack " + deferStack + ";"
+				tryPending = true
+			}
+		} else if strings.HasPrefix(trimmedLine, "for ") {
+			newLine = ForLoop(line)
+			breakables = append(breakables, &breakable{depth: curlyCount - 1, label: label})
+			if label != "" {
+				// The body is a block, so that a continue can jump to the end of it
+				// without jumping over the variable declarations in it
+				newLine += "\n{"
+			}
+		} else if strings.HasPrefix(trimmedLine, "switch ") {
+			var b *breakable
+			newLine, b = Switch(line)
+			b.depth = curlyCount - 1
+			b.label = label
+			breakables = append(breakables, b)
+		} else if m := labelRegexp.FindStringSubmatch(trimmedLine); m != nil && m[1] != "default" {
+			// A label is followed by an empty statement, since declarations can not be labeled in C++
+			newLine = m[1] + ":;"
+			nextLabel = m[1]
+		} else if (strings.HasPrefix(trimmedLine, "break ") || strings.HasPrefix(trimmedLine, "continue ")) && len(strings.Fields(trimmedLine)) == 2 {
+			newLine = BranchToLabel(trimmedLine)
+		} else if strings.HasPrefix(trimmedLine, "case ") {
+			newLine = Case(line, innermostSwitch())
+		} else if trimmedLine == "break" && len(breakables) > 0 && breakables[len(breakables)-1].isSwitch {
+			// A break in a switch ends the switch, and not the surrounding loop
+			b := breakables[len(breakables)-1]
+			if b.breakLabel == "" {
+				b.breakLabel = LabelName()
+				labelCounter++
+			}
+			newLine = "goto " + b.breakLabel + "; // break"
 		} else if strings.HasPrefix(trimmedLine, "return") {
-			if strings.HasPrefix(currentReturnType, tupleType) {
+			if strings.HasPrefix(currentReturnType, tupleType) && len(functionLiterals) == 0 {
 				elems := strings.SplitN(newLine, "return ", 2)
 				newLine = "return " + currentReturnType + "{" + elems[1] + "};"
 				//} else {
 				// Just use the standard tuple
 			}
+			// The function that is returned from, which is the innermost function literal, if any
+			defers, returnType, results := functionCatchesPanics, currentReturnType, &namedResults
+			if n := len(functionLiterals); n > 0 {
+				defers, returnType, results = functionLiterals[n-1].defers, functionLiterals[n-1].returnType, &functionLiterals[n-1].namedResults
+			}
+			if defers && !unfinishedDeferFunction {
+				value := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "return"))
+				if strings.HasPrefix(value, namedResultsMarker+"(") {
+					// The named results are returned after the deferred calls have been made
+					*results = value[len(namedResultsMarker)+1 : len(value)-1]
+					newLine = "{ " + deferStack + ".run(); return " + ResultsValue(*results, returnType) + "; }"
+				} else {
+					if strings.HasPrefix(returnType, tupleType) {
+						value = returnType + "{" + value + "}"
+					} else if currentFunctionName == "main" && len(functionLiterals) == 0 {
+						value = "0"
+					}
+					newLine = DeferReturn(value, returnType)
+				}
+			}
 		} else if strings.HasPrefix(trimmedLine, "fmt.Print") || strings.HasPrefix(trimmedLine, "print") {
-			// _ is if "pretty print" functionality may be needed, for non-literal strings and numbers
-			var pp bool
-			newLine, pp = PrintStatement(trimmedLine)
-			if pp {
-				usePrettyPrint = true
-			}
-		} else if strings.Contains(trimmedLine, "=") && !strings.HasPrefix(trimmedLine, "var ") && !strings.HasPrefix(trimmedLine, "if ") && !strings.HasPrefix(trimmedLine, "const ") && !strings.HasPrefix(trimmedLine, "type ") {
-			elem := strings.SplitN(trimmedLine, "=", 2)
+			newLine, _ = PrintStatement(trimmedLine)
+		} else if strings.HasPrefix(trimmedLine, "delete(") {
+			args := SplitArgs(greedyBetween(trimmedLine, "(", ")"))
+			newLine = args[0] + "._delete(" + args[1] + ")"
+		} else if (strings.HasSuffix(trimmedLine, "++") || strings.HasSuffix(trimmedLine, "--")) && !strings.Contains(trimmedLine, "=") {
+			n := len(trimmedLine) - 2
+			newLine = IndexReference(trimmedLine[:n]) + trimmedLine[n:]
+		} else if assignment := indexOutsideBraces(trimmedLine, "="); assignment != -1 && !strings.HasPrefix(trimmedLine, "var ") && !strings.HasPrefix(trimmedLine, "if ") && !strings.HasPrefix(trimmedLine, "} else if ") && !strings.HasPrefix(trimmedLine, "const ") && !strings.HasPrefix(trimmedLine, "type ") {
+			// The "=" in the function literals on the line, like in "call(func() { s[0] = 3 })", are left out
+			elem := []string{trimmedLine[:assignment], trimmedLine[assignment+1:]}
 			left := strings.TrimSpace(elem[0])
 			declarationAssignment := false
 			if strings.HasSuffix(left, ":") {
 				declarationAssignment = true
 				left = left[:len(left)-1]
 			}
+			operator := ""
+			if !declarationAssignment {
+				for _, op := range assignmentOperators {
+					if strings.HasSuffix(left, op) {
+						operator = op
+						left = strings.TrimSpace(left[:len(left)-len(op)])
+						break
+					}
+				}
+			}
 			right := strings.TrimSpace(elem[1])
-			if strings.HasPrefix(right, "&") && strings.Contains(right, "{") && strings.Contains(right, "}") {
-				right = "new " + right[1:]
+			multipleNames := len(SplitArgs(left)) > 1
+			if multipleNames {
+				varNames := strings.Split(left, ",")
+				if len(varNames) == 2 {
+					// v, ok := m[k]
+					right = CommaOk(right)
+				}
+				if len(SplitArgs(right)) == len(varNames) {
+					// a, b := 1, 2
+					right = tupleType + "{" + right + "};"
+				}
 			}
-			if strings.Contains(left, ",") {
+			if multipleNames && !declarationAssignment {
+				var tiedNames []string
+				for _, name := range strings.Split(left, ",") {
+					name = strings.TrimSpace(name)
+					if name == "_" {
+						name = "std::ignore"
+					}
+					tiedNames = append(tiedNames, name)
+				}
+				newLine = "std::tie(" + strings.Join(tiedNames, ", ") + ") = " + right
+			} else if multipleNames {
 				if strings.Contains(left, "_") {
 					varNames := strings.Split(left, ",")
 					for _, name := range varNames {
@@ -1253,31 +3565,18 @@ func go2cpp(source string) string {
 					newLine = "auto [" + left + "] = " + right
 				}
 			} else if declarationAssignment {
-				if strings.HasPrefix(right, "[]") {
-					if !strings.Contains(right, "{") {
-						fmt.Fprintln(os.Stderr, "UNRECOGNIZED LINE: "+trimmedLine)
-						//newLine = line
-
-					}
-					theType := TypeReplace(leftBetween(right, "]", "{"))
-					fields := strings.SplitN(right, "{", 2)
-					newLine = theType + " " + strings.TrimSpace(left) + "[] {" + fields[1]
-				} else if strings.HasPrefix(right, "map[") {
+				if strings.HasPrefix(right, "[]") && strings.HasSuffix(right, "{") {
+					// The slice literal continues on the next lines
+					newLine = TypeReplace(strings.TrimSpace(right[:len(right)-1])) + " " + strings.TrimSpace(left) + " {"
+					closingBracketNeedsASemicolon = true
+				} else if strings.HasPrefix(right, "map[") && strings.HasSuffix(right, "{") {
+					// The map literal continues on the next lines
 					hashName := strings.TrimSpace(left)
-					encounteredHashMaps = append(encounteredHashMaps, hashName)
-
-					keyType := TypeReplace(leftBetween(right, "map[", "]"))
-					valueType := TypeReplace(leftBetween(right, "]", "{"))
-
-					closingBracket := strings.HasSuffix(strings.TrimSpace(right), "}")
-					if !closingBracket {
-						inHashMap = true
-						hashKeyType = keyType
-						newLine = "std::unordered_map<" + keyType + ", " + valueType + "> " + hashName + " {"
-					} else {
-						elements := leftBetween(right, "{", "}")
-						newLine = "std::unordered_map<" + keyType + ", " + valueType + "> " + hashName + " " + HashElements(elements, keyType, false)
-					}
+					mapType := strings.TrimSpace(right[:len(right)-1])
+					keyType, _ := MapTypes(mapType)
+					inHashMap = true
+					hashKeyType = TypeReplace(keyType)
+					newLine = TypeReplace(mapType) + " " + hashName + " {"
 				} else {
 					varName := strings.TrimSpace(left)
 					if value, found := functionVarMap[varName]; found {
@@ -1290,8 +3589,10 @@ func go2cpp(source string) string {
 
 					newLine = "auto " + varName + " = " + strings.TrimSpace(right)
 				}
+			} else if operator == "&^" {
+				newLine = IndexReference(left) + " &= ~(" + right + ")"
 			} else {
-				newLine = left + " = " + right
+				newLine = IndexReference(left) + " " + operator + "= " + right
 			}
 		} else if strings.HasPrefix(trimmedLine, "package ") {
 			continue
@@ -1305,6 +3606,9 @@ func go2cpp(source string) string {
 			continue
 		} else if strings.HasPrefix(trimmedLine, "defer ") {
 			newLine = DeferCall(line)
+			if unfinishedDeferFunction {
+				deferredLiteral, deferredLiteralCount = DeferName(), len(functionLiterals)
+			}
 		} else if strings.HasPrefix(trimmedLine, "if ") {
 			newLine = IfSentence(line)
 			// TODO: Short variable names has the potential to ruin if expressions this way, do a smarter replacement
@@ -1331,23 +3635,93 @@ func go2cpp(source string) string {
 			}
 		} else if strings.HasPrefix(trimmedLine, "type ") {
 			newLine, inStruct = TypeDeclaration(trimmedLine)
+			if inStruct {
+				encounteredStructNames = []string{}
+				currentStructName = strings.Fields(trimmedLine)[1]
+				if curlyCount == 1 {
+					structNames = append(structNames, currentStructName)
+				}
+			}
 		} else if strings.HasPrefix(trimmedLine, "const ") {
 			newLine = ConstDeclaration(trimmedLine)
 		} else if trimmedLine == "fallthrough" {
 			newLine = "goto " + LabelName() + "; // fallthrough"
 			switchLabel = LabelName()
 			labelCounter++
-		} else if unfinishedDeferFunction && trimmedLine == "}()" {
+		} else if unfinishedDeferFunction && strings.HasPrefix(trimmedLine, "}(") && strings.HasSuffix(trimmedLine, ")") {
 			unfinishedDeferFunction = false
-			newLine = "});"
+			newLine = "}" + DeferArguments(trimmedLine[1:]) + ");"
 		} else if trimmedLine == "default:" {
 			newLine = "} else { // default case"
+			if b := innermostSwitch(); b.firstCase {
+				b.firstCase = false
+				newLine = "if (true) { // default case"
+			}
 			if switchLabel != "" {
 				newLine += "\n" + switchLabel + ":"
 				switchLabel = ""
 			}
 		}
 
+		// The end of a for loop or a switch
+		if n := len(breakables); n > 0 && strings.HasPrefix(trimmedLine, "}") && curlyCount == breakables[n-1].depth {
+			b := breakables[n-1]
+			breakables = breakables[:n-1]
+			if !b.isSwitch && b.label != "" {
+				// End the block of the loop body
+				if b.continueLabel != "" {
+					newLine = "}\n" + b.continueLabel + ":;\n" + newLine
+				} else {
+					newLine = "}\n" + newLine
+				}
+			}
+			if b.isSwitch && !b.firstCase {
+				// End the last case, and then the block of the switch
+				newLine += "\n}"
+			}
+			if b.breakLabel != "" {
+				newLine += "\n" + b.breakLabel + ":;"
+			}
+		}
+
+		var literal *functionLiteral
+		if !inMultilineString {
+			newLine = replaceIdentifier(ArrayLiterals(MakeSlice(MakeMap(MapLiterals(TypeArguments(newLine))))), "nil", "nullptr")
+			newLine = replaceIdentifier(replaceIdentifier(newLine, "append", "_go_append"), "cap", "_go_cap")
+			newLine, literal = FunctionLiterals(newLine, currentFunctionName != "")
+			if literal != nil {
+				literal.depth = curlyCount - 1
+				// A function literal that defers calls has a defer stack of its own, like a function
+				literal.defers = BlockDefers(sourceLines[i+1:])
+				if literal.defers {
+					newLine += "\n_go_defer_stack " + deferStack + ";"
+					tryPending = true
+				}
+				functionLiterals = append(functionLiterals, literal)
+			} else if n := len(functionLiterals); n > 0 && strings.HasPrefix(trimmedLine, "}") && curlyCount == functionLiterals[n-1].depth {
+				// The end of a function literal that spans several lines
+				if functionLiterals[n-1].defers {
+					newLine = PanicCatch("", functionLiterals[n-1].returnType, functionLiterals[n-1].namedResults) + newLine
+				}
+				functionLiterals = functionLiterals[:n-1]
+				if trimmedLine == "}" {
+					newLine += ";"
+				}
+			}
+			newLine = replaceIdentifier(replaceIdentifier(newLine, "panic", "_go_panic"), "recover", "_go_panic_recover")
+			if strings.Contains(newLine, recoverPlaceholder) && (currentFunctionName != "" || len(functionLiterals) > 0) {
+				// The function that recover() is called in, which is the innermost function literal, if any.
+				// In the statements that are translated on their own, the caller replaces the placeholder.
+				function := currentFunctionName
+				if unfinishedDeferFunction && len(functionLiterals) == deferredLiteralCount {
+					function = deferredLiteral
+				} else if len(functionLiterals) > 0 || function == literalFunctionName {
+					function = ""
+				}
+				newLine = strings.Replace(newLine, recoverPlaceholder, RecoverCall(function), -1)
+			}
+		}
+
 		if cppHasStdFormat {
 			// Special case for fmt.Sprintf -> std::format
 			if strings.Contains(newLine, "fmt.Sprintf(") && strings.Contains(newLine, "%v") {
@@ -1355,14 +3729,31 @@ func go2cpp(source string) string {
 			}
 		}
 
-		if currentFunctionName == "main" && trimmedLine == "}" && curlyCount == 0 { // curlyCount has already been decreased for this line
-			newLine = strings.Replace(trimmedLine, "}", "return 0;\n}", 1)
+		if currentFunctionName != "" && trimmedLine == "}" && curlyCount == 0 { // curlyCount has already been decreased for this line
+			if currentFunctionName == "main" {
+				newLine = strings.Replace(trimmedLine, "}", "return 0;\n}", 1)
+			}
+			if functionCatchesPanics {
+				newLine = PanicCatch(currentFunctionName, currentReturnType, namedResults) + newLine
+				functionCatchesPanics = false
+			}
+			currentFunctionName = ""
+			currentReturnType = ""
 		}
 
-		if strings.HasSuffix(trimmedLine, "}") {
+		// A line like "return T{1, 2}" ends with a literal and not with a closing bracket
+		endsWithLiteral := strings.HasSuffix(trimmedLine, "}") && !strings.HasPrefix(trimmedLine, "}") && strings.Count(trimmedLine, "{") == strings.Count(trimmedLine, "}")
+		if endsWithLiteral && !inStruct && !inHashMap {
+			newLine += ";"
+		} else if endsWithLiteral && inStruct {
+			// The braces of a field type like interface{} or struct{} do not end the struct
+		} else if strings.HasSuffix(trimmedLine, "}") {
 			// If the struct is being closed, add a semicolon
 			if inStruct {
-				// Create a _str() method for this struct
+				// Create a _str() method for this struct, and equality and hashing if it is comparable
+				if ComparableType(currentStructName) {
+					newLine = CreateHashMethod(currentStructName, encounteredStructNames) + newLine
+				}
 				newLine = CreateStrMethod(encounteredStructNames) + newLine + ";"
 
 				inStruct = false
@@ -1372,7 +3763,7 @@ func go2cpp(source string) string {
 			}
 			newLine += "\n"
 		}
-		if (!strings.HasSuffix(newLine, ";") && !has(endings, lastchar(trimmedLine)) || strings.Contains(trimmedLine, "=")) && !strings.HasPrefix(trimmedLine, "//") && (!has(endings, lastchar(newLine)) && !strings.Contains(newLine, "//")) {
+		if !strings.HasSuffix(newLine, ";") && (!has(endings, lastchar(trimmedLine)) || strings.Contains(trimmedLine, "=")) && !strings.HasPrefix(trimmedLine, "//") && (!has(endings, lastchar(newLine)) && !strings.Contains(newLine, "//")) {
 			if !inMultilineString {
 				newLine += ";"
 			}
@@ -1397,17 +3788,12 @@ func go2cpp(source string) string {
 
 		lines = append(lines, newLine)
 	}
-	output := strings.Join(lines, "\n")
-
-	// The order matters
-	output = LiteralStrings(output)
-	output = WholeProgramReplace(output)
-
-	// The order matters
-	output = AddFunctions(output, usePrettyPrint, len(encounteredStructNames) > 0)
-	output = AddIncludes(output)
-
-	return output
+	// The structs are declared before everything else, so that they can be pointed to before they are defined
+	var declarations []string
+	for _, name := range structNames {
+		declarations = append(declarations, "class "+name+";")
+	}
+	return strings.Join(append(declarations, lines...), "\n")
 }
 
 func main() {
@@ -1417,28 +3803,61 @@ func main() {
 	debug := false
 	compile := true
 	clangFormat := true
+	sourceMapFilename := ""
+
+	// Options that start with "--" and contain "=" may be given anywhere
+	args := []string{os.Args[0]}
+	for _, arg := range os.Args[1:] {
+		if arg == "--no-runtime-checks" {
+			runtimeChecks = false
+		} else if arg == "--explain-escapes" {
+			explainEscapes = true
+		} else if strings.HasPrefix(arg, "--source-map=") {
+			sourceMapFilename = strings.TrimPrefix(arg, "--source-map=")
+		} else if strings.HasPrefix(arg, "--memory=") {
+			memoryManagement = strings.TrimPrefix(arg, "--memory=")
+			if !has([]string{"refcount", "gc"}, memoryManagement) {
+				log.Fatal("The memory management must be refcount or gc")
+			}
+		} else if strings.HasPrefix(arg, "--map-order=") {
+			mapIterationOrder = strings.TrimPrefix(arg, "--map-order=")
+			if !has([]string{"unordered", "random", "insertion"}, mapIterationOrder) {
+				log.Fatal("The map iteration order must be unordered, random or insertion")
+			}
+		} else {
+			args = append(args, arg)
+		}
+	}
 
 	inputFilename := ""
-	if len(os.Args) > 1 {
-		if os.Args[1] == "--version" {
+	if len(args) > 1 {
+		if args[1] == "--version" {
 			fmt.Println(versionString)
 			return
-		} else if os.Args[1] == "--help" {
+		} else if args[1] == "--help" {
 			fmt.Println("supported arguments:")
 			fmt.Println(" a .go file as the first argument")
 			fmt.Println("supported options:")
 			fmt.Println(" -o : Format with clang format")
 			fmt.Println(" -O : Don't format with clang format")
+			fmt.Println(" --map-order=unordered : Iterate over maps in the order of std::unordered_map (default)")
+			fmt.Println(" --map-order=random : Iterate over maps from a random starting point, like Go")
+			fmt.Println(" --map-order=insertion : Iterate over maps in insertion order, for reproducible runs")
+			fmt.Println(" --memory=refcount : Free values when the last pointer to them is gone, but never free cycles (default)")
+			fmt.Println(" --memory=gc : Free values with a tracing garbage collector, also cycles that do not go through slices, maps or function values")
+			fmt.Println(" --explain-escapes : List the allocations, and why they escape to the heap")
+			fmt.Println(" --source-map=FILE : Write the Go names of the identifiers that are renamed in C++ to FILE")
+			fmt.Println(" --no-runtime-checks : Don't check for nil pointers, division by zero and indices out of range")
 			return
 		}
-		inputFilename = os.Args[1]
+		inputFilename = args[1]
 	}
-	if len(os.Args) > 2 {
-		if os.Args[2] == "-o" {
+	if len(args) > 2 {
+		if args[2] == "-o" {
 			clangFormat = true
-		} else if os.Args[2] == "-O" {
+		} else if args[2] == "-O" {
 			clangFormat = false
-		} else if os.Args[2] != "-o" {
+		} else if args[2] != "-o" {
 			log.Fatal("The second argument must be -o (format sources with clang-format) or -O (don't format sources with clang-format)")
 		}
 	}
@@ -1458,21 +3877,24 @@ func main() {
 		return
 	}
 
-	cppSource := ""
+	// Translate once, since the errors and the explanations are written while translating
+	cppSource := go2cpp(string(sourceData))
+	if sourceMapFilename != "" {
+		if err := WriteSourceMap(sourceMapFilename); err != nil {
+			log.Fatal(err)
+		}
+	}
 	if clangFormat {
 		cmd := exec.Command("clang-format", "-style={BasedOnStyle: Webkit, ColumnLimit: 99}")
-		cmd.Stdin = strings.NewReader(go2cpp(string(sourceData)))
+		cmd.Stdin = strings.NewReader(cppSource)
 		var out bytes.Buffer
 		cmd.Stdout = &out
 		err = cmd.Run()
 		if err != nil {
 			log.Println("clang-format is not available, the output will look ugly!")
-			cppSource = go2cpp(string(sourceData))
 		} else {
 			cppSource = out.String()
 		}
-	} else {
-		cppSource = go2cpp(string(sourceData))
 	}
 
 	if !compile {
@@ -1487,12 +3909,12 @@ func main() {
 	tempFileName := tempFile.Name()
 	defer os.Remove(tempFileName)
 
-	// Compile the string in cppSource
+	// Compile the string in cppSource. With -fwrapv, signed integers wrap around when they overflow, like in Go.
 	cpp := "g++"
 	if cppenv := os.Getenv("CXX"); cppenv != "" {
 		cpp = cppenv
 	}
-	cmd2 := exec.Command(cpp, "-x", "c++", "-std=c++2a", "-O2", "-pipe", "-fPIC", "-Wfatal-errors", "-fpermissive", "-Wno-address-of-temporary", "-s", "-o", tempFileName, "-")
+	cmd2 := exec.Command(cpp, "-x", "c++", "-std=c++2a", "-O2", "-fwrapv", "-pipe", "-fPIC", "-Wfatal-errors", "-fpermissive", "-Wno-address-of-temporary", "-s", "-o", tempFileName, "-")
 	cmd2.Stdin = strings.NewReader(cppSource)
 	var compiled bytes.Buffer
 	var errors bytes.Buffer
@@ -1503,7 +3925,7 @@ func main() {
 		//fmt.Println("Failed to compile this with g++:")
 		fmt.Println(cppSource)
 		fmt.Println("Errors:")
-		fmt.Println(errors.String())
+		fmt.Println(GoNames(errors.String()))
 		log.Fatal(err)
 	}
 	compiledBytes, err := ioutil.ReadFile(tempFileName)
@@ -1511,8 +3933,8 @@ func main() {
 		log.Fatal(err)
 	}
 	outputFilename := ""
-	if len(os.Args) > 3 {
-		outputFilename = os.Args[3]
+	if len(args) > 3 {
+		outputFilename = args[3]
 	}
 	if outputFilename != "" {
 		err = ioutil.WriteFile(outputFilename, compiledBytes, 0755)
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,7 +14,37 @@ import (
 const testcaseDirectory = "testcases/"
 
 var testPrograms = []string{
-	//"multiline_map",
+	"identifiers",
+	"initialization",
+	"declarations",
+	"selectors",
+	"escapes",
+	"pointers",
+	"multiline_map",
+	"composite_literals",
+	"zero_values",
+	"complex",
+	"literals",
+	"integers",
+	"constants",
+	"labels",
+	"for_forms",
+	"deadlock",
+	"init_statements",
+	"named_results",
+	"variadic",
+	"methods",
+	"function_types",
+	"closures",
+	"defer_semantics",
+	"defer_panics",
+	"slices",
+	"runtime_errors",
+	"index_out_of_range",
+	"panic",
+	"panic_recover",
+	"map_struct_key",
+	"map_semantics",
 	"multiline_string",
 	"var_string",
 	"var_multi",
@@ -60,6 +91,27 @@ var unorderedOutput = []string{
 	"for_range_map_key",
 }
 
+// Programs that panic and exit with status 2. Only stderr up to the goroutine
+// line is compared, since go2cpp prints no stack trace.
+var panickingPrograms = []string{
+	"panic",
+	"defer_panics",
+	"index_out_of_range",
+	"deadlock",
+}
+
+// panicHeader returns the lines of s up to and including the first goroutine line
+func panicHeader(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "goroutine ") {
+			lines = lines[:i+1]
+			break
+		}
+	}
+	return strings.Join(lines, "")
+}
+
 func assertEqual(t *testing.T, a interface{}, b interface{}, message string) {
 	if a == b {
 		return
@@ -102,8 +154,9 @@ func TestPrograms(t *testing.T) {
 
 		// Program output when running with "go run"
 		fmt.Println("[go  ] Compiling and running " + gofile + " (using go run)...")
+		panics := has(panickingPrograms, program)
 		stdoutGo, stderrGo, err := Run("go run " + gofile)
-		if err != nil {
+		if err != nil && !panics {
 			t.Fatal(err)
 		}
 
@@ -111,6 +164,15 @@ func TestPrograms(t *testing.T) {
 		fmt.Println("[ c++] Compiling and running " + gofile + " (using go2cpp and g++)...")
 		Run("./go2cpp " + gofile + " -o " + filepath.Join(testcaseDirectory, program))
 		stdoutTgc, stderrTgc, err := Run(filepath.Join(testcaseDirectory, program))
+		if panics {
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+				err = nil
+			} else {
+				t.Fatal(gofile + " should panic and exit with status 2")
+			}
+			stderrGo = panicHeader(stderrGo)
+			stderrTgc = panicHeader(stderrTgc)
+		}
 		if err != nil {
 			cmd := "./go2cpp " + gofile + " -O"
 			if stdoutT, stderrT, err := Run(cmd); err != nil {
@@ -149,3 +211,215 @@ func TestPrograms(t *testing.T) {
 		assertEqual(t, stderrGo, stderrTgc, "go2cpp and go run should produce the same output on stderr")
 	}
 }
+
+// Check that maps are iterated over in insertion order with --map-order=insertion,
+// and from different starting points with --map-order=random
+func TestMapIterationOrder(t *testing.T) {
+	Run("go build")
+	gofile := filepath.Join(testcaseDirectory, "map_order.go")
+	executable := filepath.Join(testcaseDirectory, "map_order")
+	defer os.Remove(executable)
+
+	Run("./go2cpp " + gofile + " -o " + executable + " --map-order=insertion")
+	stdout, _, err := Run(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		assertEqual(t, line, "hgfdcba", "the map should be iterated over in insertion order, not as "+line)
+	}
+
+	Run("./go2cpp " + gofile + " -o " + executable + " --map-order=random")
+	stdout, _, err = Run(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		assertEqual(t, len(line), 7, "every key should be visited once, not as "+line)
+		orders[line] = true
+	}
+	if len(orders) < 2 {
+		t.Fatal("the map should be iterated over from different starting points")
+	}
+}
+
+// Check that programs can be compiled without the runtime checks
+func TestNoRuntimeChecks(t *testing.T) {
+	Run("go build")
+	gofile := filepath.Join(testcaseDirectory, "for_range_list.go")
+	executable := filepath.Join(testcaseDirectory, "for_range_list_unchecked")
+	defer os.Remove(executable)
+
+	stdoutGo, _, err := Run("go run " + gofile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run("./go2cpp " + gofile + " -o " + executable + " --no-runtime-checks")
+	stdout, _, err := Run(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, stdoutGo, stdout, "go2cpp --no-runtime-checks and go run should produce the same output on stdout")
+}
+
+// Check that programs with pointers can be compiled with the garbage collector
+func TestGarbageCollector(t *testing.T) {
+	Run("go build")
+	gofile := filepath.Join(testcaseDirectory, "pointers.go")
+	executable := filepath.Join(testcaseDirectory, "pointers_gc")
+	defer os.Remove(executable)
+
+	stdoutGo, _, err := Run("go run " + gofile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run("./go2cpp " + gofile + " -o " + executable + " --memory=gc")
+	stdout, _, err := Run(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, stdoutGo, stdout, "go2cpp --memory=gc and go run should produce the same output on stdout")
+}
+
+// Check that the allocations are explained, and that the ones that escape say why
+func TestExplainEscapes(t *testing.T) {
+	Run("go build")
+	gofile := filepath.Join(testcaseDirectory, "escapes.go")
+	executable := filepath.Join(testcaseDirectory, "escapes_explained")
+	defer os.Remove(executable)
+
+	_, stderr, _ := Run("./go2cpp " + gofile + " -o " + executable + " --explain-escapes")
+	for _, expected := range []string{
+		"26:9: &Vec{...} escapes to heap: returned from newVec",
+		"31:7: &Vec{...} does not escape",
+		"33:7: new(Vec) does not escape",
+		"37:2: local does not escape",
+		"51:7: &Vec{...} escapes to heap: assigned to package level variable saved (through v)",
+		"57:23: &Vec{...} escapes to heap: appended to a slice",
+	} {
+		if !strings.Contains(stderr, expected) {
+			t.Errorf("go2cpp --explain-escapes should write %q, but wrote:\n%s", expected, stderr)
+		}
+	}
+}
+
+// Check that the source map has the Go names of the identifiers that are renamed in C++
+func TestSourceMap(t *testing.T) {
+	Run("go build")
+	gofile := filepath.Join(testcaseDirectory, "identifiers.go")
+	sourceMap := filepath.Join(t.TempDir(), "identifiers.json")
+
+	if _, stderr, err := Run("./go2cpp " + gofile + " -O --source-map=" + sourceMap); err != nil {
+		t.Fatal(err, stderr)
+	}
+	data, err := ioutil.ReadFile(sourceMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"_i__class": "class"`, `"_i__new": "new"`, `"_i___s__x": "_s__x"`, `"_i__printf": "printf"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("the source map should contain %s, but is:\n%s", expected, data)
+		}
+	}
+}
+
+// assertErrors checks that go2cpp fails for the given Go program, and reports the given errors
+func assertErrors(t *testing.T, source string, messages []string) {
+	gofile := filepath.Join(t.TempDir(), "main.go")
+	if err := ioutil.WriteFile(gofile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, err := Run("./go2cpp " + gofile + " -O")
+	if err == nil {
+		t.Fatal("go2cpp should fail")
+	}
+	for _, message := range messages {
+		if !strings.Contains(stderr, message) {
+			t.Errorf("go2cpp should report %q, not: %s", message, stderr)
+		}
+	}
+}
+
+// Check that the programs that Go does not compile, but C++ may compile, are reported like Go does
+func TestErrors(t *testing.T) {
+	Run("go build")
+	for _, test := range []struct {
+		name     string
+		source   string
+		messages []string
+	}{
+		{
+			name: "labels",
+			source: `package main
+
+import "fmt"
+
+func main() {
+	goto end
+	x := 1
+	fmt.Println(x)
+end:
+	for {
+		break missing
+	}
+}
+`,
+			messages: []string{"6:7: goto end jumps over variable declaration at line 7", "11:9: invalid break label missing"},
+		},
+		{
+			name: "constants",
+			source: `package main
+
+import "fmt"
+
+const big = 1 << 100
+
+func main() {
+	var b byte = 300
+	fmt.Println(b, big)
+}
+`,
+			messages: []string{"8:15: cannot use 300 (untyped int constant) as byte value in variable declaration (overflows)", "9:17: cannot use big (untyped int constant 1267650600228229401496703205376) as int value in argument to fmt.Println (overflows)"},
+		},
+		{
+			name: "recursive types",
+			source: `package main
+
+type A struct {
+	b B
+}
+
+type B struct {
+	a [2]A
+}
+
+func main() {
+}
+`,
+			messages: []string{"3:6: invalid recursive type A", "3:6: A refers to B", "7:6: B refers to A"},
+		},
+		{
+			name: "map keys",
+			source: `package main
+
+import "fmt"
+
+type Key struct {
+	parts []string
+}
+
+func main() {
+	m := map[[]int]string{}
+	var n map[Key]int
+	fmt.Println(len(m), len(n))
+}
+`,
+			messages: []string{"10:11: invalid map key type []int", "11:12: invalid map key type Key"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assertErrors(t, test.source, test.messages)
+		})
+	}
+}
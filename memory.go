@@ -0,0 +1,189 @@
+package main
+
+// pointerRuntime is the C++ code for pointers, for each of the ways that memoryManagement can
+// free the values that pointers point to. The code for all of them has a _go_ptr<T> class,
+// which is used like T*, and can be made from a std::shared_ptr<T> that owns the value.
+var pointerRuntime = map[string]string{
+	"refcount": `
+// _go_ptr is a pointer, like *T in Go. The values are reference counted, and freed when the
+// last pointer to them is gone. Values that point to each other in a cycle, like the nodes of
+// a doubly linked list, are never freed. Use --memory=gc for programs that leave such cycles.
+template <typename T>
+class _go_ptr {
+    std::shared_ptr<T> _p;
+
+public:
+    using element_type = T;
+
+    _go_ptr() = default;
+    _go_ptr(std::nullptr_t) {}
+    explicit _go_ptr(std::shared_ptr<T> p)
+        : _p { std::move(p) }
+    {
+    }
+    auto operator*() const -> T& { return *_p; }
+    auto operator->() const -> T* { return _p.get(); }
+    auto get() const -> T* { return _p.get(); }
+    // _owner returns what keeps the value alive, for pointers to the parts of the value
+    auto _owner() const -> const std::shared_ptr<T>& { return _p; }
+    auto _hash() const -> std::size_t { return std::hash<T*> {}(_p.get()); }
+    friend auto operator==(const _go_ptr& a, const _go_ptr& b) -> bool { return a.get() == b.get(); }
+    friend auto operator==(const _go_ptr& a, std::nullptr_t) -> bool { return a.get() == nullptr; }
+};
+`,
+	"gc": `
+// _go_gc is a mark and sweep garbage collector for the values that _go_new allocates. The roots
+// are the pointers that are not inside of an allocated value, like the local variables, and the
+// pointers in slices, maps and function values. A collection frees the values that can not be
+// reached from the roots, and happens when the number of values has doubled since the last one.
+// Since the pointers in slices, maps and function values are roots, the cycles through them are
+// never freed.
+class _go_gc {
+public:
+    // _pointer is the part of _go_ptr that the collector follows
+    struct _pointer {
+        void* _address = nullptr;
+        _pointer() { _go_gc::heap()._pointers.insert(this); }
+        _pointer(const _pointer& p)
+            : _address { p._address }
+        {
+            _go_gc::heap()._pointers.insert(this);
+        }
+        auto operator=(const _pointer& p) -> _pointer& = default;
+        ~_pointer() { _go_gc::heap()._pointers.erase(this); }
+    };
+
+    static auto heap() -> _go_gc&
+    {
+        static auto* h = new _go_gc; // never destroyed, since pointers may outlive it
+        return *h;
+    }
+
+    template <typename T, typename... A>
+    auto allocate(A&&... value) -> T*
+    {
+        if (_objects.size() >= _threshold) {
+            collect();
+            _threshold = std::max<std::size_t>(1024, 2 * _objects.size());
+        }
+        T* p = new T(std::forward<A>(value)...);
+        _objects.emplace(reinterpret_cast<const char*>(p), _object { sizeof(T), [](void* p) { delete static_cast<T*>(p); } });
+        return p;
+    }
+
+    void collect()
+    {
+        // The pointers inside of each value, and the roots
+        std::unordered_map<_object*, std::vector<const _pointer*>> inside;
+        std::vector<_object*> work;
+        auto mark = [&](const _pointer* p) {
+            if (auto* o = find(p->_address); o != nullptr && !o->marked) {
+                o->marked = true;
+                work.push_back(o);
+            }
+        };
+        std::vector<const _pointer*> roots;
+        for (const auto* p : _pointers) {
+            if (auto* o = find(p); o != nullptr) {
+                inside[o].push_back(p);
+            } else {
+                roots.push_back(p);
+            }
+        }
+        for (const auto* p : roots) {
+            mark(p);
+        }
+        while (!work.empty()) {
+            auto* o = work.back();
+            work.pop_back();
+            for (const auto* p : inside[o]) {
+                mark(p);
+            }
+        }
+        std::vector<std::pair<void*, void (*)(void*)>> garbage;
+        for (auto it = _objects.begin(); it != _objects.end();) {
+            if (it->second.marked) {
+                it->second.marked = false;
+                ++it;
+            } else {
+                garbage.emplace_back(const_cast<char*>(it->first), it->second.destroy);
+                it = _objects.erase(it);
+            }
+        }
+        for (auto [p, destroy] : garbage) {
+            destroy(p);
+        }
+    }
+
+private:
+    struct _object {
+        std::size_t size;
+        void (*destroy)(void*);
+        bool marked = false;
+    };
+    // The values by address, so that the value that an address is inside of can be found
+    std::map<const char*, _object> _objects;
+    std::unordered_set<const _pointer*> _pointers;
+    std::size_t _threshold = 1024;
+
+    auto find(const void* address) -> _object*
+    {
+        auto a = static_cast<const char*>(address);
+        auto it = _objects.upper_bound(a);
+        if (it == _objects.begin()) {
+            return nullptr;
+        }
+        --it;
+        return a < it->first + it->second.size ? &it->second : nullptr;
+    }
+};
+
+// _go_ptr is a pointer, like *T in Go. The values that _go_new allocates are freed by _go_gc.
+// Pointers to the parts of other values, like the elements of slices, keep the other value alive.
+template <typename T>
+class _go_ptr : _go_gc::_pointer {
+    std::shared_ptr<T> _keep;
+
+public:
+    using element_type = T;
+
+    _go_ptr() = default;
+    _go_ptr(std::nullptr_t) {}
+    explicit _go_ptr(T* p) { _address = p; }
+    explicit _go_ptr(std::shared_ptr<T> p)
+        : _keep { std::move(p) }
+    {
+        _address = _keep.get();
+    }
+    auto operator*() const -> T& { return *get(); }
+    auto operator->() const -> T* { return get(); }
+    auto get() const -> T* { return static_cast<T*>(_address); }
+    // _owner returns what keeps the value alive, if it is not allocated by _go_new
+    auto _owner() const -> const std::shared_ptr<T>& { return _keep; }
+    auto _hash() const -> std::size_t { return std::hash<T*> {}(get()); }
+    friend auto operator==(const _go_ptr& a, const _go_ptr& b) -> bool { return a.get() == b.get(); }
+    friend auto operator==(const _go_ptr& a, std::nullptr_t) -> bool { return a.get() == nullptr; }
+};
+`,
+}
+
+// newRuntime is the C++ code for _go_new, that allocates the values that pointers point to,
+// for each of the ways that memoryManagement can free them
+var newRuntime = map[string]string{
+	"refcount": `
+// _go_new allocates a value, like new(T) and &T{...} in Go
+template <typename T, typename... A>
+auto _go_new(A&&... value) -> _go_ptr<T>
+{
+    return _go_ptr<T> { std::make_shared<T>(std::forward<A>(value)...) };
+}
+`,
+	"gc": `
+// _go_new allocates a value, like new(T) and &T{...} in Go
+template <typename T, typename... A>
+auto _go_new(A&&... value) -> _go_ptr<T>
+{
+    return _go_ptr<T> { _go_gc::heap().allocate<T>(std::forward<A>(value)...) };
+}
+`,
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const methodPrefix = "_go_method_"
+
+var posType = reflect.TypeOf(token.NoPos)
+
+// Methods rewrites the methods that are declared in the main package to
+// functions that take the receiver as the first argument, and rewrites
+// the method calls, method values and method expressions accordingly:
+//
+//	func (c *Counter) Add(n int) {  ->  func _go_method_Counter_Add(c *Counter, n int) {
+//	c.Add(2)                        ->  _go_method_Counter_Add(&c, 2)
+//	f := c.Add                      ->  f := func(_go_receiver *Counter) func(int) { ... }(&c)
+//	g := (*Counter).Add             ->  g := _go_method_Counter_Add
+//
+// The receiver is taken the address of, or dereferenced, as needed.
+// Method values evaluate and bind the receiver when they are created.
+func Methods(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	found := false
+	for _, decl := range file.Decls {
+		if f, ok := decl.(*ast.FuncDecl); ok && f.Recv != nil && methodFunction(info.Defs[f.Name], pkg) != "" {
+			found = true
+		}
+	}
+	if !found {
+		return source
+	}
+
+	// Calls, before the selectors in them are seen as method values
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			methodCall(call, info, pkg)
+		}
+		return true
+	})
+
+	// Method values and method expressions
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		if sel, ok := e.(*ast.SelectorExpr); ok {
+			return methodValue(sel, info, pkg)
+		}
+		return e
+	})
+
+	// Declarations
+	for _, decl := range file.Decls {
+		f, ok := decl.(*ast.FuncDecl)
+		if !ok || f.Recv == nil {
+			continue
+		}
+		name := methodFunction(info.Defs[f.Name], pkg)
+		if name == "" {
+			continue
+		}
+		recv := f.Recv.List[0]
+		if len(recv.Names) == 0 || recv.Names[0].Name == "_" {
+			recv.Names = []*ast.Ident{ast.NewIdent("_go_receiver")}
+		}
+		f.Type.Params.List = append([]*ast.Field{recv}, f.Type.Params.List...)
+		f.Name = &ast.Ident{NamePos: f.Name.Pos(), Name: name}
+		f.Recv = nil
+	}
+
+	return formatFile(fset, file)
+}
+
+// methodFunction returns the name of the function that the given method
+// is rewritten to, or "" if it is not a method that is declared in the main package
+func methodFunction(obj types.Object, pkg *types.Package) string {
+	f, ok := obj.(*types.Func)
+	if !ok || f.Pkg() != pkg || pkg == nil {
+		return ""
+	}
+	recv := f.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+		return ""
+	}
+	return methodPrefix + named.Obj().Name() + "_" + f.Name()
+}
+
+// methodValue rewrites a selector of a method in the main package, that is not
+// a method call, to a function that has the receiver bound to it, or that takes
+// the receiver as the first argument
+func methodValue(sel *ast.SelectorExpr, info *types.Info, pkg *types.Package) ast.Expr {
+	selection := info.Selections[sel]
+	if selection == nil || selection.Kind() == types.FieldVal {
+		return sel
+	}
+	name := methodFunction(selection.Obj(), pkg)
+	if name == "" {
+		return sel
+	}
+	sig := selection.Obj().Type().(*types.Signature)
+	_, wantsPointer := sig.Recv().Type().(*types.Pointer)
+
+	if selection.Kind() == types.MethodExpr {
+		_, isPointer := selection.Recv().(*types.Pointer)
+		if wantsPointer == isPointer && len(selection.Index()) == 1 {
+			return &ast.Ident{NamePos: sel.Pos(), Name: name}
+		}
+		// (*T).M, where M has a value receiver
+		receiverType := goTypeString(selection.Recv())
+		receiver := receiverExpr(ast.NewIdent("_go_receiver"), selection)
+		return methodFuncLit(name, sig, "_go_receiver "+receiverType+", ", exprString(receiver), sel.Pos())
+	}
+
+	// A method value binds the receiver when it is created
+	receiver := receiverExpr(sel.X, selection)
+	receiverType := goTypeString(sig.Recv().Type())
+	inner := methodFuncLit(name, sig, "", "_go_receiver", sel.Pos())
+	outer := "func(_go_receiver " + receiverType + ") " + funcTypeString(sig) + " { return " + exprString(inner) + " }(" + exprString(receiver) + ")"
+	return parseGenerated(outer, sel.Pos())
+}
+
+// methodCall rewrites a call of a method in the main package to a call of the
+// function that takes the receiver as the first argument
+func methodCall(call *ast.CallExpr, info *types.Info, pkg *types.Package) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	selection := info.Selections[sel]
+	if selection == nil || selection.Kind() != types.MethodVal {
+		return
+	}
+	name := methodFunction(selection.Obj(), pkg)
+	if name == "" {
+		return
+	}
+	call.Fun = &ast.Ident{NamePos: sel.Pos(), Name: name}
+	call.Args = append([]ast.Expr{receiverExpr(sel.X, selection)}, call.Args...)
+}
+
+// receiverExpr returns the receiver for the method in the given selection,
+// by selecting the embedded fields, and taking the address or dereferencing as needed
+func receiverExpr(x ast.Expr, selection *types.Selection) ast.Expr {
+	t := selection.Recv()
+	path := selection.Index()
+	for _, i := range path[:len(path)-1] {
+		st := t
+		if p, ok := st.Underlying().(*types.Pointer); ok {
+			st = p.Elem()
+			x = deref(x)
+		}
+		field := st.Underlying().(*types.Struct).Field(i)
+		x = &ast.SelectorExpr{X: x, Sel: ast.NewIdent(field.Name())}
+		t = field.Type()
+	}
+	_, isPointer := t.Underlying().(*types.Pointer)
+	_, wantsPointer := selection.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer)
+	switch {
+	case wantsPointer && !isPointer:
+		return &ast.UnaryExpr{OpPos: x.Pos(), Op: token.AND, X: x}
+	case !wantsPointer && isPointer:
+		return &ast.StarExpr{Star: x.Pos(), X: x}
+	}
+	return x
+}
+
+// methodFuncLit creates a function literal that calls the given function with the
+// given receiver and the parameters of the function literal, after the given parameters
+func methodFuncLit(name string, sig *types.Signature, extraParams, receiver string, pos token.Pos) ast.Expr {
+	var params, args []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		arg := "_go_arg" + strconv.Itoa(i)
+		paramType := goTypeString(sig.Params().At(i).Type())
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			paramType = "..." + strings.TrimPrefix(paramType, "[]")
+			arg += "..."
+		}
+		params = append(params, strings.TrimSuffix(arg, "...")+" "+paramType)
+		args = append(args, arg)
+	}
+	call := name + "(" + strings.Join(append([]string{receiver}, args...), ", ") + ")"
+	body := call
+	if sig.Results().Len() > 0 {
+		body = "return " + call
+	}
+	lit := "func(" + extraParams + strings.Join(params, ", ") + ") " + resultsString(sig) + " { " + body + " }"
+	return parseGenerated(lit, pos)
+}
+
+// parseGenerated parses a generated Go expression, that replaces the expression at
+// the given position. The positions that are set are moved to it, since the positions
+// of the parsed expression would otherwise refer to unrelated lines in the source code.
+func parseGenerated(expr string, pos token.Pos) ast.Expr {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		panic("could not parse the generated expression " + expr + ": " + err.Error())
+	}
+	ast.Inspect(e, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		movePositions(n, pos)
+		return true
+	})
+	return e
+}
+
+// movePositions moves the positions that are set in the given node to the given position
+func movePositions(n ast.Node, pos token.Pos) {
+	v := reflect.ValueOf(n).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Type() == posType && v.Field(i).Int() != int64(token.NoPos) {
+			v.Field(i).SetInt(int64(pos))
+		}
+	}
+}
+
+// funcTypeString returns the Go function type of the given signature,
+// without the receiver and the parameter names
+func funcTypeString(sig *types.Signature) string {
+	return "func(" + typesString(sig.Params(), sig.Variadic()) + ") " + resultsString(sig)
+}
+
+// resultsString returns the results of the given signature, as they are written in Go
+func resultsString(sig *types.Signature) string {
+	results := typesString(sig.Results(), false)
+	if sig.Results().Len() > 1 {
+		return "(" + results + ")"
+	}
+	return results
+}
+
+// typesString returns the types in the given tuple, separated by commas
+func typesString(tuple *types.Tuple, variadic bool) string {
+	var names []string
+	for i := 0; i < tuple.Len(); i++ {
+		name := goTypeString(tuple.At(i).Type())
+		if variadic && i == tuple.Len()-1 {
+			name = "..." + strings.TrimPrefix(name, "[]")
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// exprString returns the given expression as Go source code
+func exprString(e ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), e); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Pointers rewrites the expressions that make pointers to calls of the functions that
+// make _go_ptr values, which free what they point to when it is no longer used:
+//
+//	new(T)      ->  _go_new(T)
+//	&T{1, 2}    ->  _go_new(T, T{1, 2})
+//	&p.f        ->  _go_address(&p.f, p)
+//	&s[i].f     ->  _go_address(&s[i].f, s[i], s)
+//	&(*x).a[i]  ->  _go_address(&(*x).a[i], (*x).a, x)
+//
+// The other arguments of _go_address are the values that the part may be inside of, and
+// the first one that is a pointer or a slice keeps the part alive. Which one that is depends
+// on the types, so it is found by the C++ compiler. The local variables that have their
+// address taken are already boxed by Closures, so &x is not found for them.
+func Pointers(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{Uses: map[*ast.Ident]types.Object{}}
+	checkSource(fset, file, info)
+
+	changed := false
+	replaceExprs(file, func(e ast.Expr) ast.Expr {
+		switch x := e.(type) {
+		case *ast.CallExpr:
+			if id, ok := x.Fun.(*ast.Ident); ok && len(x.Args) == 1 {
+				if b, ok := info.Uses[id].(*types.Builtin); ok && b.Name() == "new" {
+					changed = true
+					return call("_go_new", e, x.Args[0])
+				}
+			}
+		case *ast.UnaryExpr:
+			if x.Op != token.AND {
+				break
+			}
+			changed = true
+			if lit, ok := x.X.(*ast.CompositeLit); ok && lit.Type != nil {
+				return call("_go_new", e, parseGenerated(exprString(lit.Type), lit.Pos()), lit)
+			}
+			return call("_go_address", e, append([]ast.Expr{x}, owners(x.X, info)...)...)
+		}
+		return e
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// owners returns the expressions that the given addressable expression may be a part of,
+// from the innermost one. The expressions that contain calls are left out, since they
+// would be evaluated twice, and so are packages.
+func owners(e ast.Expr, info *types.Info) []ast.Expr {
+	var result []ast.Expr
+	for {
+		var x ast.Expr
+		switch part := e.(type) {
+		case *ast.ParenExpr:
+			e = part.X
+			continue
+		case *ast.SelectorExpr:
+			x = part.X
+		case *ast.IndexExpr:
+			x = part.X
+		case *ast.StarExpr:
+			x = part.X
+		}
+		if x == nil || hasCall(x) {
+			return result
+		}
+		if id, ok := x.(*ast.Ident); ok {
+			if _, ok := info.Uses[id].(*types.PkgName); ok {
+				return result
+			}
+		}
+		result = append(result, copyExpr(x))
+		if _, ok := e.(*ast.StarExpr); ok {
+			// A pointer is always the owner
+			return result
+		}
+		e = x
+	}
+}
+
+// hasCall checks if the given expression contains a call, or a conversion
+func hasCall(e ast.Expr) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// RangeFunctions rewrites the for loops that range over functions, like
+// iter.Seq and iter.Seq2, to calls of the functions, with the loop body as
+// the yield function:
+//
+//	for v := range seq {  ->  seq(func(v int) bool {
+//	    if v > 2 {        ->      if v > 2 {
+//	        break         ->          return false
+//	    }                 ->      }
+//	    fmt.Println(v)    ->      fmt.Println(v)
+//	}                     ->      return true
+//	                      ->  })
+//
+// A return in the loop body makes the yield function return false, and then
+// the surrounding function return the values that were given to it.
+func RangeFunctions(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	checkSource(fset, file, info)
+
+	// The statement lists, and the functions that they are in. The lists are
+	// found before the lists in them, and are rewritten in the reverse order,
+	// so that the loops in a loop body are rewritten before the loop.
+	type stmtList struct {
+		list     *[]ast.Stmt
+		funcType *ast.FuncType
+	}
+	var lists []stmtList
+	var funcTypes []*ast.FuncType
+	var nodes []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			switch nodes[len(nodes)-1].(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				funcTypes = funcTypes[:len(funcTypes)-1]
+			}
+			nodes = nodes[:len(nodes)-1]
+			return true
+		}
+		nodes = append(nodes, n)
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			funcTypes = append(funcTypes, n.Type)
+		case *ast.FuncLit:
+			funcTypes = append(funcTypes, n.Type)
+		case *ast.BlockStmt:
+			lists = append(lists, stmtList{&n.List, funcTypes[len(funcTypes)-1]})
+		case *ast.CaseClause:
+			lists = append(lists, stmtList{&n.Body, funcTypes[len(funcTypes)-1]})
+		case *ast.CommClause:
+			lists = append(lists, stmtList{&n.Body, funcTypes[len(funcTypes)-1]})
+		}
+		return true
+	})
+
+	counter := 0
+	for i := len(lists) - 1; i >= 0; i-- {
+		var result []ast.Stmt
+		for _, stmt := range *lists[i].list {
+			loop, label := rangeStmt(stmt)
+			if loop == nil || info.Types[loop.X].Type == nil {
+				result = append(result, stmt)
+				continue
+			}
+			sig, ok := info.Types[loop.X].Type.Underlying().(*types.Signature)
+			if !ok {
+				result = append(result, stmt)
+				continue
+			}
+			counter++
+			result = append(result, rangeFunction(loop, label, sig, lists[i].funcType, strconv.Itoa(counter))...)
+		}
+		*lists[i].list = result
+	}
+	if counter == 0 {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// rangeStmt returns the for loop with range in the given statement, and its label, if it has one
+func rangeStmt(stmt ast.Stmt) (*ast.RangeStmt, string) {
+	if labeled, ok := stmt.(*ast.LabeledStmt); ok {
+		loop, _ := labeled.Stmt.(*ast.RangeStmt)
+		return loop, labeled.Label.Name
+	}
+	loop, _ := stmt.(*ast.RangeStmt)
+	return loop, ""
+}
+
+// rangeFunction returns the statements that replace a for loop that ranges over a function
+// with the given signature, in the function with the given type. The suffix makes the names
+// of the variables that are declared unique.
+func rangeFunction(loop *ast.RangeStmt, label string, sig *types.Signature, funcType *ast.FuncType, suffix string) []ast.Stmt {
+	pos := loop.Pos()
+	end := loop.Body.Rbrace
+	yield := sig.Params().At(0).Type().Underlying().(*types.Signature)
+
+	// The parameters of the yield function are the loop variables
+	var params []string
+	var assigns []ast.Stmt
+	for i := 0; i < yield.Params().Len(); i++ {
+		e := loop.Key
+		if i == 1 {
+			e = loop.Value
+		}
+		name := "_"
+		if id, ok := e.(*ast.Ident); ok && loop.Tok == token.DEFINE {
+			name = id.Name
+		} else if e != nil && !isBlank(e) {
+			// for k, v = range seq assigns to the variables
+			name = "_go_range_value" + strconv.Itoa(i)
+			assigns = append(assigns, &ast.AssignStmt{Lhs: []ast.Expr{e}, TokPos: pos, Tok: token.ASSIGN, Rhs: idents(pos, name)})
+		}
+		params = append(params, name+" "+goTypeString(yield.Params().At(i).Type()))
+	}
+
+	// The values that a return in the loop body gives are kept in the named results,
+	// or in variables that are declared before the loop
+	var results []string
+	var decls []ast.Stmt
+	named := false
+	if funcType.Results != nil {
+		for i, field := range funcType.Results.List {
+			if len(field.Names) > 0 {
+				named = true
+				for _, id := range field.Names {
+					results = append(results, id.Name)
+				}
+				continue
+			}
+			name := "_go_range_result" + suffix + "_" + strconv.Itoa(i)
+			results = append(results, name)
+			spec := &ast.ValueSpec{Names: []*ast.Ident{{NamePos: pos, Name: name}}, Type: field.Type}
+			decls = append(decls, &ast.DeclStmt{Decl: &ast.GenDecl{TokPos: pos, Tok: token.VAR, Specs: []ast.Spec{spec}}})
+		}
+	}
+	r := &rangeBody{exit: "_go_range_exit" + suffix, label: label, results: results, inner: map[string]bool{}}
+	ast.Inspect(loop.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.LabeledStmt:
+			r.inner[n.Label.Name] = true
+		}
+		return true
+	})
+	loop.Body.List = r.stmts(loop.Body.List, false, false)
+	loop.Body.List = append(assigns, loop.Body.List...)
+	loop.Body.List = append(loop.Body.List, &ast.ReturnStmt{Return: end, Results: idents(end, "true")})
+
+	lit := parseGenerated("func("+strings.Join(params, ", ")+") bool {}", pos).(*ast.FuncLit)
+	lit.Body = loop.Body
+	call := &ast.ExprStmt{X: &ast.CallExpr{Fun: loop.X, Lparen: pos, Args: []ast.Expr{lit}, Rparen: end}}
+	if !r.returns && len(r.branches) == 0 {
+		return []ast.Stmt{call}
+	}
+
+	// After the call, return or branch like the loop body did
+	spec := &ast.ValueSpec{Names: []*ast.Ident{{NamePos: pos, Name: r.exit}}, Type: idents(pos, "int")[0]}
+	decls = append([]ast.Stmt{&ast.DeclStmt{Decl: &ast.GenDecl{TokPos: pos, Tok: token.VAR, Specs: []ast.Spec{spec}}}}, decls...)
+	stmts := append(decls, call)
+	exitIf := func(code int, stmt ast.Stmt) {
+		cond := &ast.BinaryExpr{X: idents(end, r.exit)[0], OpPos: end, Op: token.EQL, Y: &ast.BasicLit{ValuePos: end, Kind: token.INT, Value: strconv.Itoa(code)}}
+		stmts = append(stmts, &ast.IfStmt{If: end, Cond: cond, Body: &ast.BlockStmt{Lbrace: end, List: []ast.Stmt{stmt}, Rbrace: end}})
+	}
+	if r.returns {
+		ret := &ast.ReturnStmt{Return: end}
+		if !named {
+			ret.Results = idents(end, results...)
+		}
+		exitIf(1, ret)
+	}
+	for i, branch := range r.branches {
+		exitIf(i+2, &ast.BranchStmt{TokPos: end, Tok: branch.Tok, Label: &ast.Ident{NamePos: end, Name: branch.Label.Name}})
+	}
+	return stmts
+}
+
+// rangeBody rewrites the returns, breaks and continues in the body of a loop that ranges
+// over a function, to returns from the yield function. The exit variable tells if the loop
+// body returned, or ended with a break or continue of a loop outside of it, and which.
+type rangeBody struct {
+	exit     string            // the variable that tells how the loop body ended: 1 for a return, 2 and up for the branches
+	label    string            // the label of the loop, if it has one
+	results  []string          // the variables that are assigned the values that the loop body returns
+	inner    map[string]bool   // the labels in the loop body
+	returns  bool              // a return was found in the loop body
+	branches []*ast.BranchStmt // the breaks and continues of loops outside of the loop body
+}
+
+// stmts rewrites the given statements. The breaks and continues in loops in the
+// loop body, and the breaks in switches and selects, are left as they are.
+func (r *rangeBody) stmts(list []ast.Stmt, inLoop, inSwitch bool) []ast.Stmt {
+	var result []ast.Stmt
+	for _, stmt := range list {
+		result = append(result, r.stmt(stmt, inLoop, inSwitch)...)
+	}
+	return result
+}
+
+// exitWith returns the statements that end the loop body with the given exit code
+func (r *rangeBody) exitWith(code int, pos token.Pos) []ast.Stmt {
+	value := &ast.BasicLit{ValuePos: pos, Kind: token.INT, Value: strconv.Itoa(code)}
+	return []ast.Stmt{
+		&ast.AssignStmt{Lhs: idents(pos, r.exit), TokPos: pos, Tok: token.ASSIGN, Rhs: []ast.Expr{value}},
+		&ast.ReturnStmt{Return: pos, Results: idents(pos, "false")},
+	}
+}
+
+func (r *rangeBody) stmt(stmt ast.Stmt, inLoop, inSwitch bool) []ast.Stmt {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		r.returns = true
+		var stmts []ast.Stmt
+		if len(s.Results) > 0 {
+			stmts = append(stmts, &ast.AssignStmt{Lhs: idents(s.Pos(), r.results...), TokPos: s.Pos(), Tok: token.ASSIGN, Rhs: s.Results})
+		}
+		return append(stmts, r.exitWith(1, s.Pos())...)
+	case *ast.BranchStmt:
+		switch {
+		case s.Tok != token.BREAK && s.Tok != token.CONTINUE:
+		case s.Label != nil && s.Label.Name == r.label:
+			// A break or continue of this loop, from a loop or switch in the loop body
+			return r.branch(s)
+		case s.Label != nil && !r.inner[s.Label.Name]:
+			// A break or continue of a loop outside of the loop body
+			r.branches = append(r.branches, s)
+			return r.exitWith(len(r.branches)+1, s.Pos())
+		case s.Label != nil:
+		case s.Tok == token.BREAK && !inLoop && !inSwitch, s.Tok == token.CONTINUE && !inLoop:
+			return r.branch(s)
+		}
+	case *ast.BlockStmt:
+		s.List = r.stmts(s.List, inLoop, inSwitch)
+	case *ast.IfStmt:
+		s.Body.List = r.stmts(s.Body.List, inLoop, inSwitch)
+		if s.Else != nil {
+			s.Else = r.block(r.stmt(s.Else, inLoop, inSwitch))
+		}
+	case *ast.LabeledStmt:
+		s.Stmt = r.block(r.stmt(s.Stmt, inLoop, inSwitch))
+	case *ast.ForStmt:
+		s.Body.List = r.stmts(s.Body.List, true, inSwitch)
+	case *ast.RangeStmt:
+		s.Body.List = r.stmts(s.Body.List, true, inSwitch)
+	case *ast.SwitchStmt:
+		r.clauses(s.Body, inLoop)
+	case *ast.TypeSwitchStmt:
+		r.clauses(s.Body, inLoop)
+	case *ast.SelectStmt:
+		r.clauses(s.Body, inLoop)
+	}
+	return []ast.Stmt{stmt}
+}
+
+// branch returns the return from the yield function that a break or continue of the loop is
+func (r *rangeBody) branch(s *ast.BranchStmt) []ast.Stmt {
+	if s.Tok == token.BREAK {
+		return []ast.Stmt{&ast.ReturnStmt{Return: s.Pos(), Results: idents(s.Pos(), "false")}}
+	}
+	return []ast.Stmt{&ast.ReturnStmt{Return: s.Pos(), Results: idents(s.Pos(), "true")}}
+}
+
+// clauses rewrites the statements in the cases of a switch or select
+func (r *rangeBody) clauses(body *ast.BlockStmt, inLoop bool) {
+	for _, clause := range body.List {
+		switch c := clause.(type) {
+		case *ast.CaseClause:
+			c.Body = r.stmts(c.Body, inLoop, true)
+		case *ast.CommClause:
+			c.Body = r.stmts(c.Body, inLoop, true)
+		}
+	}
+}
+
+// block returns the given statement, or a block with the given statements if there are several
+func (r *rangeBody) block(stmts []ast.Stmt) ast.Stmt {
+	if len(stmts) == 1 {
+		return stmts[0]
+	}
+	return &ast.BlockStmt{Lbrace: stmts[0].Pos(), List: stmts, Rbrace: stmts[0].Pos()}
+}
+
+// isBlank checks if the given expression is the blank identifier
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// idents returns identifiers with the given names, at the given position
+func idents(pos token.Pos, names ...string) []ast.Expr {
+	var exprs []ast.Expr
+	for _, name := range names {
+		exprs = append(exprs, &ast.Ident{NamePos: pos, Name: name})
+	}
+	return exprs
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// namedResultsMarker marks the returns of functions that defer calls and have
+// named results. The results are read after the deferred calls have been made.
+const namedResultsMarker = "_go_results"
+
+// NamedResults rewrites functions with named results to functions where the
+// results are declared as variables at the start of the function body:
+//
+//	func f() (n int, err error) {  ->  func f() (int, error) {
+//	                               ->      var n int
+//	                               ->      var err error
+//	return                         ->      return n, err
+//
+// In functions that defer calls, the deferred calls may change the results, so
+// the returns assign the results and then return them with namedResultsMarker:
+//
+//	return 1, nil  ->  n, err = 1, nil
+//	               ->  return _go_results(n, err)
+func NamedResults(source string) string {
+	fset, file := parseSource(source)
+	changed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch f := n.(type) {
+		case *ast.FuncDecl:
+			changed = declareResults(f.Type, f.Body, deferringBody(f.Body)) || changed
+		case *ast.FuncLit:
+			changed = declareResults(f.Type, f.Body, deferringBody(f.Body)) || changed
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// deferringBody checks if the given function body defers calls, outside of function literals
+func deferringBody(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// declareResults declares the named results of a function as variables, and
+// rewrites the returns of the function. Returns false if the results are not named.
+func declareResults(funcType *ast.FuncType, body *ast.BlockStmt, deferring bool) bool {
+	results := funcType.Results
+	if body == nil || results == nil || len(results.List) == 0 || len(results.List[0].Names) == 0 {
+		return false
+	}
+	var names []string
+	var decls []ast.Stmt
+	var fields []*ast.Field
+	for _, field := range results.List {
+		for _, id := range field.Names {
+			if id.Name == "_" {
+				id.Name = namedResultsMarker + strconv.Itoa(len(names))
+			}
+			names = append(names, id.Name)
+			// One result for each name, like "q, r int" -> "int, int"
+			fields = append(fields, &ast.Field{Type: field.Type})
+		}
+		spec := &ast.ValueSpec{Names: field.Names, Type: field.Type}
+		decls = append(decls, &ast.DeclStmt{Decl: &ast.GenDecl{TokPos: body.Lbrace, Tok: token.VAR, Specs: []ast.Spec{spec}}})
+	}
+	results.List = fields
+	idents := func(pos token.Pos) []ast.Expr {
+		var exprs []ast.Expr
+		for _, name := range names {
+			exprs = append(exprs, &ast.Ident{NamePos: pos, Name: name})
+		}
+		return exprs
+	}
+	markedReturn := func(pos token.Pos) ast.Stmt {
+		marker := &ast.CallExpr{Fun: &ast.Ident{NamePos: pos, Name: namedResultsMarker}, Lparen: pos, Args: idents(pos), Rparen: pos}
+		return &ast.ReturnStmt{Return: pos, Results: []ast.Expr{marker}}
+	}
+	rewrite := func(list []ast.Stmt) []ast.Stmt {
+		var result []ast.Stmt
+		for _, stmt := range list {
+			r, ok := stmt.(*ast.ReturnStmt)
+			switch {
+			case !ok:
+				result = append(result, stmt)
+			case deferring && len(r.Results) > 0:
+				assign := &ast.AssignStmt{Lhs: idents(r.Pos()), TokPos: r.Pos(), Tok: token.ASSIGN, Rhs: r.Results}
+				result = append(result, assign, markedReturn(r.Pos()))
+			case deferring:
+				result = append(result, markedReturn(r.Pos()))
+			case len(r.Results) == 0:
+				r.Results = idents(r.Pos())
+				result = append(result, r)
+			default:
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BlockStmt:
+			s.List = rewrite(s.List)
+		case *ast.CaseClause:
+			s.Body = rewrite(s.Body)
+		case *ast.CommClause:
+			s.Body = rewrite(s.Body)
+		}
+		return true
+	})
+	body.List = append(decls, body.List...)
+	if _, ok := body.List[len(body.List)-1].(*ast.ReturnStmt); deferring && !ok {
+		// The function returns the results after a recovered panic
+		body.List = append(body.List, markedReturn(body.Rbrace))
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// parseSource parses the Go source code that one of the rewrites before the translation
+// rewrites. CheckErrors has reported the syntax errors in the program, and the rewrites
+// generate Go source code, so source code that can not be parsed is a bug in go2cpp.
+func parseSource(source string) (*token.FileSet, *ast.File) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+	if err != nil {
+		panic("go2cpp: could not parse the rewritten source code: " + err.Error() + "\n" + source)
+	}
+	return fset, file
+}
+
+// packageImporter imports the packages for all the type checks, so that the export data of
+// each package is only read once, and not by each of the rewrites
+var packageImporter = importer.Default()
+
+// checkSource type checks the parsed source code, and fills in the given info. The type
+// errors are ignored, since CheckErrors has reported the errors in the program, and since
+// the rewrites call functions that only the generated C++ code declares, like _go_div.
+func checkSource(fset *token.FileSet, file *ast.File, info *types.Info) *types.Package {
+	conf := types.Config{Importer: packageImporter, Error: func(error) {}}
+	pkg, _ := conf.Check("main", fset, []*ast.File{file}, info)
+	return pkg
+}
+
+// formatFile returns the source code of the rewritten file
+func formatFile(fset *token.FileSet, file *ast.File) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		panic("go2cpp: could not format the rewritten source code: " + err.Error())
+	}
+	return buf.String()
+}
+
+// formatSource returns the given generated source code, formatted
+func formatSource(generated string) string {
+	formatted, err := format.Source([]byte(generated))
+	if err != nil {
+		panic("go2cpp: could not format the rewritten source code: " + err.Error() + "\n" + generated)
+	}
+	return string(formatted)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// Selectors rewrites the selectors and indices that implicitly dereference a pointer in Go, so
+// that the pointers are dereferenced explicitly, and then nil checked like other dereferences:
+//
+//	p.X         ->  (*p).X
+//	a.b.c       ->  (*(*a).b).c    (if a and a.b are pointers)
+//	arr[i]      ->  (*arr)[i]      (if arr is a pointer to an array)
+//	arr[a:b]    ->  (*arr)[a:b]
+//
+// The receivers of method calls are left to Methods, that takes the address or
+// dereferences them as needed.
+func Selectors(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	checkSource(fset, file, info)
+
+	changed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			if selection := info.Selections[x]; selection != nil && selection.Kind() == types.FieldVal {
+				if e, ok := explicitPath(x.X, selection.Recv(), selection.Index()); ok {
+					x.X = e
+					changed = true
+				}
+			}
+		case *ast.IndexExpr:
+			if pointerToArray(info.TypeOf(x.X)) {
+				x.X = deref(x.X)
+				changed = true
+			}
+		case *ast.SliceExpr:
+			if pointerToArray(info.TypeOf(x.X)) {
+				x.X = deref(x.X)
+				changed = true
+			}
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// explicitPath returns the given expression of the given type, with the embedded fields
+// in the given path selected and the pointers dereferenced, up to the last field in the
+// path. It returns false if nothing needs to be made explicit.
+func explicitPath(x ast.Expr, t types.Type, path []int) (ast.Expr, bool) {
+	changed := false
+	for i, index := range path {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			x = deref(x)
+			t = p.Elem()
+			changed = true
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return x, changed
+		}
+		if i == len(path)-1 {
+			break
+		}
+		field := st.Field(index)
+		x = &ast.SelectorExpr{X: x, Sel: &ast.Ident{NamePos: x.End(), Name: field.Name()}}
+		t = field.Type()
+		changed = true
+	}
+	return x, changed
+}
+
+// deref returns the given pointer expression, dereferenced
+func deref(x ast.Expr) ast.Expr {
+	return &ast.ParenExpr{Lparen: x.Pos(), X: &ast.StarExpr{Star: x.Pos(), X: x}, Rparen: x.End()}
+}
+
+// pointerToArray checks if the given type is a pointer to an array
+func pointerToArray(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	p, ok := t.Underlying().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	_, ok = p.Elem().Underlying().(*types.Array)
+	return ok
+}
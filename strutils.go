@@ -30,3 +30,179 @@ func splitAtAndTrim(s string, poss []int) []string {
 	l[len(poss)] = strings.TrimSpace(s[startpos:])
 	return l
 }
+
+// matchingBracket returns the position of the bracket that closes the
+// bracket found at position pos in s, or -1 if there is no such bracket.
+// Brackets within quotes are ignored.
+func matchingBracket(s string, pos int) int {
+	depth := 0
+	var quote byte
+	for i := pos; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// openingBracket returns the position of the bracket that opens the
+// bracket that is found at position pos in s, or -1 if there is none.
+// Quotes are not taken into account.
+func openingBracket(s string, pos int) int {
+	depth := 0
+	for i := pos; i >= 0; i-- {
+		switch s[i] {
+		case ')', ']', '}':
+			depth++
+		case '(', '[', '{':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// indexOutsideQuotes returns the position of the first instance of sub in s
+// that is not within a quoted string or rune, starting at position start.
+// Returns -1 if sub is not found.
+func indexOutsideQuotes(s, sub string, start int) int {
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if strings.HasPrefix(s[i:], sub) {
+			return i
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		}
+	}
+	return -1
+}
+
+// replaceIdentifier replaces all instances of the identifier a with b,
+// as long as they are not part of a longer identifier or within quotes.
+func replaceIdentifier(s, a, b string) string {
+	var sb strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			sb.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		}
+		if quote == 0 && strings.HasPrefix(s[i:], a) && (i == 0 || !isIdentifierLetter(s[i-1])) && (i+len(a) == len(s) || !isIdentifierLetter(s[i+len(a)])) {
+			sb.WriteString(b)
+			i += len(a) - 1
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// countOutsideQuotes counts the instances of sub in s that are not within quotes
+func countOutsideQuotes(s, sub string) int {
+	n := 0
+	for pos := indexOutsideQuotes(s, sub, 0); pos != -1; pos = indexOutsideQuotes(s, sub, pos+len(sub)) {
+		n++
+	}
+	return n
+}
+
+// indexOutsideBraces returns the position of the first instance of sub in s that is
+// not within quotes or curly brackets, like the body of a function literal.
+// Returns -1 if sub is not found.
+func indexOutsideBraces(s, sub string) int {
+	for pos := indexOutsideQuotes(s, sub, 0); pos != -1; pos = indexOutsideQuotes(s, sub, pos+len(sub)) {
+		if countOutsideQuotes(s[:pos], "{") == countOutsideQuotes(s[:pos], "}") {
+			return pos
+		}
+	}
+	return -1
+}
+
+// blockBrace returns the position of the "{" that starts the block of the statement in s,
+// like the body of a for loop, and not a brace of a composite literal in the statement.
+// The block is the one that continues on the next lines, or that ends at the end of s.
+// Returns -1 if there is no block.
+func blockBrace(s string) int {
+	for pos := indexOutsideQuotes(s, "{", 0); pos != -1; pos = indexOutsideQuotes(s, "{", pos+1) {
+		closing := matchingBracket(s, pos)
+		if closing == -1 || closing == len(s)-1 {
+			return pos
+		}
+		pos = closing
+	}
+	return -1
+}
+
+// splitOutsideQuotes splits a string at the given separator, but not within quotes or brackets
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+)
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func apply(f func(int) int, x int) int {
+	return f(x)
+}
+
+func adder(base int) func(int) int {
+	return func(x int) int {
+		return base + x
+	}
+}
+
+func call(f func()) {
+	f()
+}
+
+type Handler struct {
+	Name string
+	Fn   func() string
+}
+
+var operations = map[string]func(int) int{
+	"double": func(x int) int {
+		return x * 2
+	},
+}
+
+func main() {
+	next := counter()
+	next()
+	next()
+	fmt.Println("counter:", next())
+
+	other := counter()
+	fmt.Println("other counter:", other())
+
+	total := 0
+	add := func(x int) { total += x }
+	add(3)
+	add(4)
+	fmt.Println("total:", total)
+
+	s := []int{1, 2}
+	call(func() { s[0] = 3 })
+	call(func() { s[1] += 5 })
+	fmt.Println("assigned in literals:", s)
+
+	twice := func(x int) int {
+		return x * 2
+	}
+	fmt.Println("apply:", apply(twice, 21))
+	fmt.Println("adder:", apply(adder(10), 5))
+
+	var fib func(int) int
+	fib = func(n int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n-1) + fib(n-2)
+	}
+	fmt.Println("fib:", fib(10))
+
+	fmt.Println("literal argument:", apply(func(x int) int {
+		return x + 10
+	}, 1))
+	h := Handler{
+		Name: "handler",
+		Fn: func() string {
+			return "called"
+		},
+	}
+	fmt.Println("literal field:", h.Name, h.Fn())
+	count := 0
+	ops := map[string]func(){
+		"inc": func() {
+			count++
+		},
+		"add": func() {
+			count += 10
+		},
+	}
+	ops["inc"]()
+	ops["add"]()
+	fmt.Println("literal elements:", count, operations["double"](4))
+
+	var funcs []func() int
+	for i := 0; i < 3; i++ {
+		funcs = append(funcs, func() int { return i })
+	}
+	values := []int{10, 20, 30}
+	for _, v := range values {
+		funcs = append(funcs, func() int { return v })
+	}
+	for _, f := range funcs {
+		fmt.Print(f())
+		fmt.Print(" ")
+	}
+	fmt.Println()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"math/cmplx"
+)
+
+const unit = 1i
+
+func main() {
+	c := 3 + 4i
+	fmt.Println(c, real(c), imag(c))
+
+	d := complex(1.5, -2)
+	fmt.Println(d, c+d, c-d, c*d, c*2, c/2)
+	fmt.Println(c == complex(3, 4), c != d, unit*unit)
+
+	var z complex128
+	fmt.Println(z, z == 0)
+
+	var f complex64 = complex(float32(0.5), 0.25)
+	fmt.Println(f, real(f)*2, complex128(f))
+
+	x, y := 2.0, -1.0
+	e := complex(x, y)
+	fmt.Println(e, -e)
+
+	zero := 0.0
+	inf := 1 / zero
+	fmt.Println(complex(inf, -inf), complex(-1, inf), complex(zero, -inf))
+
+	fmt.Println(cmplx.Abs(c), cmplx.Sqrt(-4), cmplx.Conj(c))
+	r, theta := cmplx.Polar(1i)
+	fmt.Println(r, theta*2)
+	fmt.Println(cmplx.Abs(cmplx.Exp(1i)), cmplx.Phase(-1))
+	fmt.Println(cmplx.Rect(2, 0))
+}
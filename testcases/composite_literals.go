@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Line struct {
+	From, To Point
+	Label    string
+	Weight   float64
+}
+
+func main() {
+	// Keyed fields in any order, and fields that are left out
+	p := Point{Y: 2, X: 1}
+	q := Point{Y: 5}
+	fmt.Println(p.X, p.Y, q.X, q.Y)
+
+	l := Line{
+		Label: "diagonal",
+		To:    Point{3, 3},
+	}
+	fmt.Println(l.From.X, l.To.Y, l.Label, l.Weight)
+
+	// Elided types
+	points := []Point{{1, 2}, {3, 4}, {Y: 6}}
+	fmt.Println(len(points), points[1].X, points[2].Y)
+	pointers := []*Point{{7, 8}}
+	fmt.Println((*pointers[0]).Y)
+	named := map[string]Point{"a": {1, 2}, "b": {Y: 3}}
+	fmt.Println(named["a"].Y, named["b"].Y)
+	grid := [2][2]int{{1, 2}, {3, 4}}
+	fmt.Println(grid[1][0])
+
+	// Indices as keys
+	sparse := []int{2: 5, 7, 0: 1}
+	fmt.Println(sparse, len(sparse))
+	letters := [...]string{3: "d", 1: "b"}
+	fmt.Println(len(letters), letters[1], letters[3], letters[0] == "")
+
+	// Nested literals over several lines, with trailing commas
+	lines := []Line{
+		{
+			From:  Point{0, 0},
+			To:    Point{1, 1}, // a comment
+			Label: "first",
+		},
+		{
+			Label:  "second",
+			Weight: 2.5,
+		},
+	}
+	fmt.Println(len(lines), lines[0].To.X, lines[1].Label, lines[1].Weight)
+
+	matrix := [][]float64{
+		{1, 0, 0},
+		{0, 1, 0},
+		{0, 0, 1},
+	}
+	fmt.Println(matrix[1], len(matrix))
+
+	ages := map[string]int{
+		"alice": 31,
+		"bob":   42,
+	}
+	fmt.Println(ages["alice"], ages["bob"], len(ages))
+}
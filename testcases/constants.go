@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+type Weekday int
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const (
+	_  = iota
+	KB = 1 << (10 * iota)
+	MB
+	GB
+)
+
+const (
+	A, B = iota * 10, iota + 100
+	C, D
+)
+
+const (
+	Read = 1 << iota
+	Write
+	Execute
+)
+
+const big = 1 << 100
+
+const (
+	typed    int64   = 1 << 40
+	small    float32 = 0.25
+	pi               = 3.14159
+	half             = 1 / 2.0
+	letter           = 'a'
+	max      uint64  = 1<<64 - 1
+	greeting         = "hello" + ", " + "world"
+	ok               = big > 1000
+)
+
+func main() {
+	fmt.Println(Sunday, Monday, Tuesday)
+	fmt.Println(KB, MB, GB)
+	fmt.Println(A, B, C, D)
+	fmt.Println(Read|Write|Execute, Read, Write, Execute)
+	x := big >> 98
+	fmt.Println(x)
+	fmt.Println(typed, small, pi, half)
+	fmt.Println(letter, max)
+	fmt.Println(greeting, ok)
+	var f float64 = big / (1 << 97)
+	fmt.Println(f)
+	const local = 7 * 6
+	fmt.Println(local, local/4, local%5)
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	ch := make(chan string, 1)
+	ch <- "hello"
+	fmt.Println(<-ch)
+	// Nothing else sends to the channel
+	fmt.Println(<-ch)
+}
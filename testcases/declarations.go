@@ -0,0 +1,106 @@
+package main
+
+import "fmt"
+
+// Functions and types can be used before they are declared
+
+func main() {
+	fmt.Println(isEven(10), isOdd(7))
+	t := &Tree{}
+	t.insert(5)
+	t.insert(3)
+	t.insert(8)
+	fmt.Println(t.count(), t.root.left.value, t.root.right.value)
+	var s Shelf
+	s.boxes[1].label = Label{"second"}
+	fmt.Println(s.boxes[1].label.text, s.name == "")
+	p := Pair{1, 2}
+	fmt.Println(p.sum(), Origin)
+	var buf Buffer
+	fmt.Println(len(buf.data), buf.data[bufferSize-1])
+}
+
+const Origin Point = 0
+
+type Tree struct {
+	root *TreeNode
+	info Info
+}
+
+func (t *Tree) insert(v int) {
+	t.root = t.root.insert(v)
+	t.info.size++
+}
+
+func (t *Tree) count() int {
+	return t.info.size
+}
+
+func isEven(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return isOdd(n - 1)
+}
+
+func isOdd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return isEven(n - 1)
+}
+
+type (
+	TreeNode struct {
+		value       int
+		left, right *TreeNode
+		tree        *Tree
+	}
+
+	Info struct {
+		size int
+	}
+)
+
+func (n *TreeNode) insert(v int) *TreeNode {
+	if n == nil {
+		return &TreeNode{value: v}
+	}
+	if v < n.value {
+		n.left = n.left.insert(v)
+	} else {
+		n.right = n.right.insert(v)
+	}
+	return n
+}
+
+type Shelf struct {
+	name  string
+	boxes [3]Box
+}
+
+type Box struct {
+	label Label
+}
+
+type Label struct {
+	text string
+}
+
+type Pair Couple
+
+type Couple struct {
+	a, b int
+}
+
+func (p Pair) sum() int {
+	return p.a + p.b
+}
+
+type Point int
+
+type Buffer struct {
+	data [bufferSize]byte
+}
+
+const bufferSize = 4
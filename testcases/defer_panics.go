@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+// replaced panics in a deferred call, which replaces the panic, and recovers the new panic
+func replaced() {
+	defer func() {
+		fmt.Println("recovered the new panic:", recover())
+	}()
+	defer func() {
+		panic("second")
+	}()
+	panic("first")
+}
+
+// repanic recovers the panic in a deferred call, which then panics again
+func repanic() {
+	defer fmt.Println("deferred before the panics")
+	defer func() {
+		r := recover()
+		fmt.Println("recovered:", r)
+		panic("again")
+	}()
+	panic("first")
+}
+
+func main() {
+	replaced()
+	defer fmt.Println("outer")
+	defer func() {
+		panic("last")
+	}()
+	repanic()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+)
+
+func arguments() {
+	x := 1
+	defer fmt.Println("argument evaluated when deferring:", x)
+	defer func() {
+		fmt.Println("closure sees the latest value:", x)
+	}()
+	x = 2
+}
+
+func loop() {
+	for i := 0; i < 3; i++ {
+		defer fmt.Println("deferred in loop:", i)
+	}
+	fmt.Println("loop done")
+}
+
+func counter() int {
+	count := 0
+	defer func() {
+		count++
+		fmt.Println("count when deferred call is made:", count)
+	}()
+	count = 10
+	return count
+}
+
+func conditional(b bool) {
+	message := "deferred in block"
+	if b {
+		defer fmt.Println(message)
+	}
+	fmt.Println("conditional done")
+}
+
+func cleanup(name string, steps int) {
+	fmt.Println("cleanup", name, steps)
+}
+
+func panics() {
+	steps := 0
+	defer func() {
+		r := recover()
+		fmt.Println("recovered after", steps, "steps:", r)
+	}()
+	defer cleanup("panics", steps)
+	for i := 0; i < 5; i++ {
+		steps++
+		if i == 3 {
+			panic("step 3")
+		}
+	}
+}
+
+func hello(s string) {
+	fmt.Println("hello", s)
+}
+
+func bye(s string) {
+	fmt.Println("bye", s)
+}
+
+func functionValues() {
+	f := hello
+	defer f("deferred")
+	f = bye
+	f("now")
+
+	fs := []func(string){hello, bye}
+	i := 0
+	defer fs[i]("from a slice")
+	i = 1
+}
+
+func literals() (n int) {
+	f := func() { defer fmt.Println("deferred in a literal on one line") }
+	f()
+	g := func(n int) int {
+		defer fmt.Println("deferred in a literal:", n)
+		if n > 2 {
+			return n * 2
+		}
+		return n
+	}
+	a, b := g(1), g(3)
+	fmt.Println("literal results:", a, b)
+	div := func(a, b int) (q int) {
+		defer func() {
+			if r := recover(); r != nil {
+				q = -1
+			}
+		}()
+		return a / b
+	}
+	n = div(1, 0)
+	fmt.Println("literals done")
+	return
+}
+
+func main() {
+	arguments()
+	loop()
+	fmt.Println(counter())
+	conditional(true)
+	conditional(false)
+	panics()
+	functionValues()
+	n := literals()
+	fmt.Println("recovered in a literal:", n)
+	fmt.Println("done")
+}
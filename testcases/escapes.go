@@ -0,0 +1,65 @@
+package main
+
+import "fmt"
+
+type Vec struct {
+	X, Y int
+}
+
+var saved *Vec
+
+func length2(v *Vec) int {
+	return (*v).X*(*v).X + (*v).Y*(*v).Y
+}
+
+func scale(v *Vec, k int) {
+	(*v).X *= k
+	(*v).Y *= k
+}
+
+// keep stores the pointer in a package level variable, so what it points to escapes
+func keep(v *Vec) {
+	saved = v
+}
+
+func newVec(x, y int) *Vec {
+	return &Vec{x, y}
+}
+
+func main() {
+	// These do not escape, and are placed on the stack
+	v := &Vec{3, 4}
+	fmt.Println("length2:", length2(v))
+	w := new(Vec)
+	(*w).X = 2
+	scale(w, 5)
+	fmt.Println("scaled:", *w)
+	local := Vec{1, 1}
+	scale(&local, 3)
+	fmt.Println("local:", local)
+
+	// Each iteration has its own value
+	total := 0
+	for i := 0; i < 3; i++ {
+		p := &Vec{i, i}
+		scale(p, 2)
+		total += length2(p)
+	}
+	fmt.Println("total:", total)
+
+	// These escape to the heap
+	keep(&Vec{7, 8})
+	fmt.Println("saved:", *saved)
+	n := newVec(5, 6)
+	fmt.Println("new:", *n)
+	var ptrs []*Vec
+	for i := 0; i < 3; i++ {
+		ptrs = append(ptrs, &Vec{i, -i})
+	}
+	fmt.Println("appended:", *ptrs[0], *ptrs[2])
+	m := map[string]*Vec{}
+	q := &Vec{9, 9}
+	m["q"] = q
+	(*m["q"]).X = 10
+	fmt.Println("in map:", *q)
+}
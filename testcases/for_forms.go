@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+)
+
+func countTo(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 1; i <= n; i++ {
+			if !yield(i) {
+				fmt.Println("stopped at", i)
+				return
+			}
+		}
+	}
+}
+
+func pairs(names []string) iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for i, name := range names {
+			if !yield(i, name) {
+				return
+			}
+		}
+	}
+}
+
+// fill sends n values to a channel, that is closed when the function returns
+func fill(n int) (ch chan int) {
+	ch = make(chan int, n)
+	defer close(ch)
+	for i := 0; i < n; i++ {
+		ch <- i * 10
+	}
+	return
+}
+
+func firstAbove(limit int) int {
+	for v := range countTo(10) {
+		if v > limit {
+			return v
+		}
+	}
+	return -1
+}
+
+func main() {
+	// A loop with a condition only
+	x := 1
+	for x < 100 {
+		x *= 3
+	}
+	fmt.Println("x:", x)
+
+	// Init and post statements, and an empty init statement
+	for i, j := 0, 5; i < j; i, j = i+1, j-1 {
+		fmt.Println(i, j)
+	}
+	k := 0
+	for ; k < 3; k++ {
+	}
+	fmt.Println("k:", k)
+
+	// Ranging over integers
+	for i := range 3 {
+		fmt.Println(i)
+	}
+	total := 0
+	for range 4 {
+		total++
+	}
+	fmt.Println("total:", total)
+
+	// Ignoring the element, and assigning to existing variables
+	numbers := []int{10, 20, 30}
+	for i, _ := range numbers {
+		fmt.Println(i)
+	}
+	var index, value int
+	for index, value = range numbers {
+	}
+	fmt.Println("last:", index, value)
+
+	// Strings give byte indices and runes
+	for i, r := range "héllo" {
+		fmt.Println(i, r)
+	}
+	for _, r := range "aé" {
+		fmt.Println(r)
+	}
+
+	// Pointers to arrays
+	arr := [3]string{"a", "b", "c"}
+	p := &arr
+	for i, s := range p {
+		fmt.Println(i, s)
+	}
+
+	// Channels are received from until they are closed
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+	fmt.Println("buffered:", len(ch), cap(ch))
+	for v := range ch {
+		fmt.Println(v)
+	}
+	v, ok := <-ch
+	fmt.Println("closed:", v, ok)
+	for v := range fill(3) {
+		fmt.Println("filled:", v)
+	}
+
+	// Ranging over functions, with break, continue and return
+	for v := range countTo(5) {
+		if v == 2 {
+			continue
+		}
+		if v == 4 {
+			break
+		}
+		fmt.Println("seq:", v)
+	}
+	for i, name := range pairs([]string{"ann", "bob"}) {
+		fmt.Println("seq2:", i, name)
+	}
+	first := firstAbove(6)
+	fmt.Println("first above 6:", first)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+)
+
+type Op func(int, int) int
+
+type Unary func(int) int
+
+func hello(s string) {
+	fmt.Println("hello", s)
+}
+
+func bye(s string) {
+	fmt.Println("bye", s)
+}
+
+func main() {
+	// Named function types
+	var add Op = func(a, b int) int { return a + b }
+	var neg Unary = func(a int) int { return -a }
+	fmt.Println("named:", add(1, 2), neg(3))
+
+	// Maps of functions
+	ops := map[string]func(int, int) int{}
+	ops["add"] = add
+	ops["sub"] = func(a, b int) int { return a - b }
+	fmt.Println("map:", ops["add"](5, 2), ops["sub"](5, 2))
+
+	// Function variables that are assigned other functions
+	f := hello
+	f("a")
+	f = bye
+	f("b")
+	f = func(s string) {
+		fmt.Println("literal", s)
+	}
+	f("c")
+
+	g := func(s string) {
+		fmt.Println("g", s)
+	}
+	g("d")
+	g = hello
+	g("e")
+}
@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+// Identifiers that are C++ keywords, C library names, or that look like the names go2cpp generates
+type class struct {
+	new      int
+	this     string
+	ss, h    int
+	_private bool
+}
+
+func (c *class) delete() int {
+	return c.new * 2
+}
+
+func printf(template string) string {
+	return "printf: " + template
+}
+
+func len2(namespace []int) int {
+	auto := 0
+	for _, int := range namespace {
+		auto += int
+	}
+	return auto
+}
+
+var _s__x = 3
+var std = "std"
+
+func main() {
+	c := &class{new: 21, this: "this", ss: 1, h: 2}
+	fmt.Println(c.delete(), c.this, c.ss, c.h, c._private)
+	fmt.Println(printf("hi"))
+	fmt.Println(len2([]int{1, 2, 3}))
+	fmt.Println(_s__x, std)
+	operator := "operator"
+	fmt.Println(operator)
+	m := map[class]bool{*c: true}
+	fmt.Println(m[*c])
+	new := 1
+	new++
+	fmt.Println(new)
+}
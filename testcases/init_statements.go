@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+)
+
+func lookup(m map[string]int, key string) (int, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+func classify(n int) string {
+	switch {
+	case n < 0:
+		return "negative"
+	case n == 0:
+		return "zero"
+	case n < 10, n > 100:
+		return "small or large"
+	default:
+		return "medium"
+	}
+}
+
+func weekday(day int) string {
+	switch day {
+	case 1, 2, 3, 4, 5:
+		return "weekday"
+	case 6, 7:
+		return "weekend"
+	}
+	return "unknown"
+}
+
+func main() {
+	m := map[string]int{"one": 1, "two": 2}
+
+	if v, ok := lookup(m, "one"); ok {
+		fmt.Println("found", v)
+	}
+	if v, ok := lookup(m, "three"); ok {
+		fmt.Println("found", v)
+	} else if w, ok2 := lookup(m, "two"); ok2 {
+		fmt.Println("not found, but found", w, "and", v)
+	} else {
+		fmt.Println("neither", v, w)
+	}
+	if n := len(m); n > 1 {
+		fmt.Println("more than one:", n)
+	}
+
+	numbers := []int{-5, 0, 7, 50, 500}
+	for _, n := range numbers {
+		fmt.Println(n, classify(n))
+	}
+	for day := 0; day <= 7; day += 3 {
+		fmt.Println(day, weekday(day))
+	}
+
+	switch x := 3 * 4; x {
+	case 12:
+		fmt.Println("twelve")
+	default:
+		fmt.Println("not twelve")
+	}
+	switch y := 5; {
+	case y > 3:
+		fmt.Println("y is large")
+	}
+
+	// break in a switch ends the switch, and not the loop
+	count := 0
+	for i := 0; i < 5; i++ {
+		switch i {
+		case 2:
+			if count > 0 {
+				break
+			}
+			fmt.Println("not reached")
+		case 3:
+			continue
+		}
+		count++
+	}
+	fmt.Println("count:", count)
+
+	// Nested switches
+	for i := 0; i < 3; i++ {
+		switch i {
+		case 0:
+			fmt.Println("outer zero")
+		default:
+			switch {
+			case i == 1:
+				fmt.Println("inner one")
+				break
+			default:
+				fmt.Println("inner other")
+			}
+			fmt.Println("after inner switch")
+		}
+	}
+
+	switch {
+	default:
+		fmt.Println("only default")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "fmt"
+
+// The variables are initialized after the variables that they depend on
+var total = sum(values)
+
+var values = []int{a, b, c}
+
+var (
+	a = b + 1
+	b = 2
+	c int
+)
+
+var order []string
+
+var greeting = greet("world")
+
+// Several variables can be initialized by one call, or with one value each
+var head, rest = split(greeting)
+
+var doubled, label = a * 2, "doubled"
+
+func split(s string) (string, string) {
+	order = append(order, "split")
+	return s[:5], s[6:]
+}
+
+func sum(xs []int) int {
+	s := 0
+	for _, x := range xs {
+		s += x
+	}
+	order = append(order, "sum")
+	return s
+}
+
+func greet(name string) string {
+	order = append(order, "greet")
+	return "hello " + name
+}
+
+// The init functions run after the variables are initialized, in the order they are declared in
+func init() {
+	order = append(order, "first init")
+	c = 10
+}
+
+func main() {
+	fmt.Println(total, values, a, b, c)
+	fmt.Println(greeting)
+	fmt.Println(head, rest, doubled, label)
+	fmt.Println(order)
+}
+
+func init() {
+	order = append(order, "second init")
+}
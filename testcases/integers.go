@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+func main() {
+	// int is 64 bits
+	x := 1
+	for i := 0; i < 40; i++ {
+		x *= 2
+	}
+	fmt.Println(x, len("abc")*1000000000000)
+
+	// Signed integers wrap around
+	var maxInt int64 = 9223372036854775807
+	maxInt++
+	fmt.Println(maxInt)
+	var small int8 = 127
+	small++
+	fmt.Println(small)
+	var i32 int32 = 2147483647
+	i32 += 10
+	fmt.Println(i32)
+
+	// Unsigned integers
+	var u uint = 0
+	u--
+	fmt.Println(u)
+	var b byte = 200
+	var c byte = 100
+	fmt.Println(b+c, b*2, -b, c-b)
+	sum := b + c
+	fmt.Println(sum > 250)
+
+	// Shifts
+	var n uint = 70
+	one := 1
+	fmt.Println(one<<n, one<<63, -8>>n, 1<<(n-10))
+	var ub uint8 = 0x81
+	fmt.Println(ub<<1, ub>>1, ub<<n)
+	s := -16
+	s >>= n
+	fmt.Println(s)
+	s = 3
+	s <<= 2
+	fmt.Println(s)
+
+	// Bit clear and complement
+	flags := 0xff
+	mask := 0x0f
+	fmt.Println(flags&^mask, ^mask, ^ub)
+	flags &^= 0xf0
+	fmt.Println(flags)
+
+	// Conversions between integer types
+	big := 300
+	neg := -1
+	fmt.Println(int8(big), uint8(big), uint16(neg), uint32(neg), uint64(neg), int64(uint32(neg)))
+
+	// Conversions from floating point numbers truncate
+	f := 2.9
+	g := -2.9
+	huge := 1e20
+	fmt.Println(int(f), int(g), int32(huge), int64(huge), uint64(huge), uint8(f*100))
+
+	// Runes
+	r := 'a'
+	r += 2
+	fmt.Println(r, r*1000000)
+}
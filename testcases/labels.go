@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+)
+
+func find(grid [][]int, target int) (int, int) {
+	row, col := -1, -1
+outer:
+	for i, line := range grid {
+		for j, v := range line {
+			if v == target {
+				row, col = i, j
+				break outer
+			}
+		}
+	}
+	return row, col
+}
+
+func countTo(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 1; i <= n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func deferred() (count int) {
+	defer func() {
+		count *= 10
+	}()
+loop:
+	for i := 0; i < 5; i++ {
+		switch {
+		case i == 1:
+			continue loop
+		case i == 3:
+			break loop
+		}
+		count++
+	}
+	return count
+}
+
+func main() {
+	grid := [][]int{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}
+	r, c := find(grid, 5)
+	fmt.Println("found:", r, c)
+	r, c = find(grid, 10)
+	fmt.Println("not found:", r, c)
+
+	// continue with a label skips the rest of the outer loop body,
+	// including the declarations in it
+rows:
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 3; j++ {
+			if i == 1 {
+				continue rows
+			}
+			if i == 3 && j == 1 {
+				break rows
+			}
+		}
+		last := i * 10
+		fmt.Println("row done:", i, last)
+	}
+
+	// break with a label out of a switch in a loop
+	n := 0
+numbers:
+	for {
+		switch n {
+		case 4:
+			break numbers
+		default:
+			n++
+		}
+	}
+	fmt.Println("n:", n)
+
+	// select statements, and break out of them
+	ch := make(chan int, 5)
+	for i := 1; i <= 4; i++ {
+		ch <- i
+	}
+	close(ch)
+receive:
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				break receive
+			}
+			if v == 2 {
+				break
+			}
+			fmt.Println("received:", v)
+		}
+	}
+	empty := make(chan string, 1)
+	select {
+	case s := <-empty:
+		fmt.Println("unexpected:", s)
+	default:
+		fmt.Println("nothing to receive")
+	}
+	empty <- "hi"
+	select {
+	case empty <- "full":
+		fmt.Println("unexpected send")
+	case s := <-empty:
+		fmt.Println("received:", s)
+	}
+
+	// Labeled loops over functions
+	total := 0
+sequence:
+	for v := range countTo(10) {
+		for w := range countTo(3) {
+			if w == 2 {
+				continue sequence
+			}
+			if v == 4 {
+				break sequence
+			}
+			total += v * w
+		}
+	}
+	fmt.Println("total:", total)
+
+	// goto
+	i := 0
+again:
+	if i < 3 {
+		i++
+		goto again
+	}
+	fmt.Println("i:", i)
+	count := deferred()
+	fmt.Println("count:", count)
+}
@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+func main() {
+	// Escapes in strings
+	fmt.Println("\x41B", "caf\u00e9", "é", "\U0001F600", "\101\102", "tab\tend", "quote\"s", "back\\slash")
+	fmt.Println(len("\x41B"), len("é"), len("\U0001F600"), len("\xff\xfe"), "\xffA"[1])
+
+	// Raw strings, also several on one line, and with )" in them
+	raw := `C:\path\to\file`
+	fmt.Println(raw, `a)"b`, `"quoted"`, len(`\n`))
+	multi := `first line
+	second "line"
+)" third`
+	fmt.Println(multi)
+
+	// NUL bytes and slashes, which C++ does not take to end the string or to start a comment
+	nul := "x\x00y"
+	fmt.Println("a\x00b", nul, len(nul), nul[1], "http://example.com") // a comment
+	fmt.Println("a/*b*/c", `//`)
+
+	// Runes
+	r := '世'
+	fmt.Println(r, 'a', '\n', '\'', '\\', '\x41', '\u00e9', '\U0001F600', '\101')
+
+	// Number formats. This file is not gofmt-clean on purpose, since gofmt would write the
+	// upper case prefixes of 0B11, 0O17 and 0XfF in lower case, and they are to be translated too.
+	fmt.Println(0b1010, 0B11, 0o17, 0O17, 017, 0x1F, 0XfF, 1_000_000, 0x_FF, 0b_1_0)
+	fmt.Println(0x1p-2, 0x1.8p1, 1_000.5, 1e3, .5, 1., 6.02e23)
+	big := uint64(0xFFFF_FFFF_FFFF_FFFF)
+	fmt.Println(big, 9_223_372_036_854_775_807)
+}
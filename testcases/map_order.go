@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	m := map[string]int{}
+	keys := []string{"h", "g", "f", "e", "d", "c", "b", "a"}
+	for i, key := range keys {
+		m[key] = i
+	}
+	for k := range m {
+		if k == "e" {
+			delete(m, k)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		for k := range m {
+			fmt.Print(k)
+		}
+		fmt.Println()
+	}
+}
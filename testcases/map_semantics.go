@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+)
+
+type Inventory struct {
+	counts map[string]int
+}
+
+func total(m map[string]int) int {
+	sum := 0
+	for _, v := range m {
+		sum += v
+	}
+	return sum
+}
+
+func fruits() map[string]int {
+	return map[string]int{"apple": 3}
+}
+
+func main() {
+	m := make(map[string]int, 10)
+	m["a"] = 1
+	m["b"] += 2
+	m["c"]++
+	v, ok := m["a"]
+	fmt.Println(v, ok)
+	v, ok = m["missing"]
+	fmt.Println(v, ok)
+	fmt.Println(m["also missing"], len(m))
+	delete(m, "a")
+	_, ok = m["a"]
+	fmt.Println(ok, len(m), total(m))
+
+	var nilMap map[string]int
+	fmt.Println(nilMap["x"], len(nilMap), nilMap == nil)
+	for k := range nilMap {
+		fmt.Println("never", k)
+	}
+
+	inv := Inventory{make(map[string]int)}
+	inv.counts["pear"] = 7
+	fmt.Println(inv.counts["pear"], len(inv.counts))
+
+	for k, v := range fruits() {
+		fmt.Println(k, v)
+	}
+	for k := range map[string]int{"literal": 1} {
+		fmt.Println(k)
+	}
+	for _, d := range []int{1, 2, 3} {
+		fmt.Println(d)
+	}
+
+	empty := map[int]bool{}
+	empty[4] = true
+	fmt.Println(empty[4], empty[5])
+	fmt.Println(m)
+}
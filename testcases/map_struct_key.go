@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+)
+
+type Point struct {
+	X, Y int
+}
+
+type Segment struct {
+	From Point
+	To   Point
+}
+
+func main() {
+	visits := map[Point]int{}
+	visits[Point{1, 2}]++
+	visits[Point{1, 2}]++
+	visits[Point{3, 4}] = 10
+	fmt.Println(visits[Point{1, 2}], visits[Point{3, 4}], visits[Point{5, 6}], len(visits))
+
+	seen := make(map[[2]int]bool)
+	seen[[2]int{1, 2}] = true
+	_, found := seen[[2]int{1, 2}]
+	fmt.Println(found, seen[[2]int{2, 1}])
+
+	lengths := map[Segment]string{}
+	lengths[Segment{Point{0, 0}, Point{3, 4}}] = "five"
+	fmt.Println(lengths[Segment{Point{0, 0}, Point{3, 4}}])
+	fmt.Println(Point{1, 2} == Point{1, 2}, Point{1, 2} == Point{2, 1})
+
+	grid := map[[2]string][3]int{}
+	grid[[2]string{"a", "b"}] = [...]int{1, 2, 3}
+	fmt.Println(grid[[2]string{"a", "b"}], grid)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+)
+
+type Counter struct {
+	name  string
+	count int
+}
+
+func (c *Counter) Add(n int) {
+	(*c).count += n
+}
+
+func (c Counter) Get() int {
+	return c.count
+}
+
+func (c Counter) Describe(prefix string) string {
+	return prefix + c.name
+}
+
+type Handler struct {
+	name   string
+	handle func(int) int
+}
+
+func square(x int) int {
+	return x * x
+}
+
+func compose(f, g func(int) int) func(int) int {
+	return func(x int) int {
+		return g(f(x))
+	}
+}
+
+func report(name string) {
+	r := recover()
+	if r != nil {
+		fmt.Println(name, "recovered:", r)
+	}
+}
+
+func callNil() {
+	defer report("callNil")
+	var f func(int) int
+	fmt.Println("nil function:", f == nil)
+	f(1)
+}
+
+func main() {
+	c := Counter{"clicks", 0}
+	c.Add(2)
+	c.Add(3)
+	fmt.Println("get:", c.Get())
+
+	// A method value binds the receiver when it is created
+	add := c.Add
+	get := c.Get
+	add(10)
+	fmt.Println("after add:", c.Get(), "bound copy:", get())
+
+	// Method expressions take the receiver as the first argument
+	describe := Counter.Describe
+	fmt.Println(describe(c, "counter "))
+	addTo := (*Counter).Add
+	addTo(&c, 100)
+	getFrom := (*Counter).Get
+	fmt.Println("method expressions:", getFrom(&c))
+
+	// Function values in struct fields, slices and maps
+	h := Handler{"square", square}
+	fmt.Println(h.name, h.handle(7))
+	var empty Handler
+	fmt.Println("nil field:", empty.handle == nil)
+
+	fs := []func(int) int{square, compose(square, square)}
+	for _, f := range fs {
+		fmt.Println(f(3))
+	}
+	ops := map[string]func(a, b int) int{
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+	}
+	fmt.Println(ops["add"](2, 3), ops["sub"](2, 3))
+
+	var g func(int) int
+	if g == nil {
+		g = square
+	}
+	fmt.Println("assigned:", g(5), g != nil)
+
+	callNil()
+}
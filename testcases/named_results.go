@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func divmod(a, b int) (q, r int) {
+	q = a / b
+	r = a % b
+	return
+}
+
+func zero() (n int, s string) {
+	return
+}
+
+func explicitValue() (n int) {
+	n = 1
+	return 2
+}
+
+func doubled() (n int) {
+	defer func() {
+		n *= 2
+	}()
+	n = 3
+	return
+}
+
+func replaced() (n int) {
+	defer func() {
+		n += 10
+	}()
+	return 5
+}
+
+func local() int {
+	x := 1
+	defer func() {
+		x = 100
+	}()
+	return x
+}
+
+func safeDivide(a, b int) (q int, msg string) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			msg = "recovered"
+		}
+	}()
+	q = 7
+	q = a / b
+	msg = "ok"
+	return
+}
+
+func both() (_ int, label string) {
+	label = "label"
+	return 42, label
+}
+
+func parse(s string) (n int, err error) {
+	n = 1
+	if s == "" {
+		err = errors.New("empty")
+		return
+	}
+	return
+}
+
+func main() {
+	q, r := divmod(17, 5)
+	fmt.Println(q, r)
+	n, s := zero()
+	fmt.Println(n, s == "")
+	fmt.Println(explicitValue(), doubled(), replaced(), local())
+	safe, msg := safeDivide(10, 2)
+	fmt.Println(safe, msg)
+	safe, msg = safeDivide(1, 0)
+	fmt.Println(safe, msg)
+	x, label := both()
+	fmt.Println(x, label)
+	parsed, err := parse("x")
+	fmt.Println(parsed, err == nil, err)
+	parsed, err = parse("")
+	if err != nil {
+		fmt.Println(parsed, err.Error(), err)
+	}
+}
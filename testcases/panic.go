@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+)
+
+func cleanup() {
+	fmt.Println("deferred calls are made before the program exits")
+}
+
+func main() {
+	defer cleanup()
+	fmt.Println("about to panic")
+	panic("something went wrong")
+}
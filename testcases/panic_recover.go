@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+)
+
+func safeDivide(a, b int) int {
+	defer func() {
+		r := recover()
+		if r != nil {
+			fmt.Println("recovered:", r)
+		}
+	}()
+	if b == 0 {
+		panic("division by zero")
+	}
+	return a / b
+}
+
+func inner() {
+	defer fmt.Println("deferred in inner")
+	panic(42)
+}
+
+func outer() {
+	defer func() {
+		fmt.Println("recovered in outer:", recover())
+	}()
+	inner()
+	fmt.Println("not reached")
+}
+
+// helper recovers a panic only when it is the deferred function
+func helper() {
+	fmt.Println("helper recovered:", recover())
+}
+
+func indirect() {
+	defer func() {
+		fmt.Println("recovered in indirect:", recover())
+	}()
+	defer func() {
+		helper()
+	}()
+	panic("indirect")
+}
+
+func direct() {
+	defer helper()
+	panic("direct")
+}
+
+func main() {
+	fmt.Println(safeDivide(10, 2))
+	fmt.Println(safeDivide(1, 0))
+	outer()
+	indirect()
+	direct()
+	fmt.Println("recover outside of a panic:", recover())
+	var m map[string]int
+	defer func() {
+		fmt.Println(recover())
+	}()
+	m["a"] = 1
+}
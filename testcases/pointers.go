@@ -0,0 +1,125 @@
+package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Node struct {
+	value      int
+	prev, next *Node
+}
+
+var origin Point
+
+// counter returns a pointer to a local variable, which lives on after the function returns
+func counter(start int) *int {
+	n := start
+	return &n
+}
+
+func push(head *Node, value int) *Node {
+	return &Node{value: value, next: head}
+}
+
+// ring makes a doubly linked list where the last node points back to the first one
+func ring(n int) *Node {
+	first := &Node{value: 0}
+	last := first
+	for i := 1; i < n; i++ {
+		node := &Node{value: i, prev: last}
+		(*last).next = node
+		last = node
+	}
+	(*last).next = first
+	(*first).prev = last
+	return first
+}
+
+func move(p *Point, dx int) {
+	(*p).X += dx
+}
+
+func main() {
+	c := counter(5)
+	*c += 2
+	fmt.Println("counter:", *c)
+
+	p := &Point{1, 2}
+	q := new(Point)
+	(*q).Y = 7
+	fmt.Println(p, *q, q == p, q != nil)
+
+	// Moving a local variable through a pointer
+	pt := Point{3, 4}
+	move(&pt, 10)
+	fmt.Println("moved:", pt)
+	px := &pt.X
+	*px = 100
+	fmt.Println("field:", pt)
+
+	var list *Node
+	for i := 0; i < 5; i++ {
+		list = push(list, i)
+	}
+	sum := 0
+	for n := list; n != nil; n = (*n).next {
+		sum += (*n).value
+	}
+	fmt.Println("list sum:", sum)
+
+	// A pointer to an element keeps the elements alive
+	s := []int{1, 2, 3}
+	e := &s[1]
+	s = nil
+	*e = 20
+	fmt.Println("element:", *e, s == nil)
+
+	a := [3]int{1, 2, 3}
+	f := &a[2]
+	*f = 30
+	fmt.Println("array:", a)
+
+	o := &origin.X
+	*o = 4
+	fmt.Println("origin:", origin)
+
+	x := 1
+	ptr := &x
+	pp := &ptr
+	**pp = 9
+	fmt.Println("pointer to pointer:", x)
+
+	// Closures and pointers share the same variable
+	y := 0
+	inc := func() { y++ }
+	py := &y
+	inc()
+	*py += 10
+	fmt.Println("shared:", y)
+
+	points := []*Point{{1, 2}, &Point{3, 4}}
+	for _, point := range points {
+		move(point, 1)
+	}
+	fmt.Println("points:", *points[0], *points[1])
+
+	seen := map[*Point]bool{p: true}
+	fmt.Println("seen:", seen[p], seen[q])
+
+	// Rings are cycles, which are only freed by the garbage collector
+	total := 0
+	var kept *Node
+	for i := 0; i < 5000; i++ {
+		r := ring(10)
+		total += (*(*r).next).value
+		if i == 1234 {
+			kept = r
+		}
+	}
+	fmt.Println("rings:", total, (*(*kept).prev).value)
+
+	var np *Point
+	fmt.Println("nil:", np == nil, np)
+}
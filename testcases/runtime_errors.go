@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+)
+
+func report(name string) {
+	fmt.Println(name+":", recover())
+}
+
+func divide(a, b int) int {
+	defer report("division")
+	return a / b
+}
+
+func remainder(a, b int) int {
+	defer report("remainder")
+	return a % b
+}
+
+func index(l []int, i int) int {
+	defer report("index")
+	return l[i]
+}
+
+func store(l []int, i, x int) {
+	defer report("store")
+	l[i] = x
+}
+
+func slice(l []int, a, b int) []int {
+	defer report("slice")
+	return l[a:b]
+}
+
+func letter(s string, i int) byte {
+	defer report("string")
+	return s[i]
+}
+
+func literal(i int) byte {
+	defer report("literal")
+	return "abc"[i]
+}
+
+func deref(p *int) int {
+	defer report("pointer")
+	return *p
+}
+
+func str(x interface{}) string {
+	defer report("conversion")
+	return x.(string)
+}
+
+func main() {
+	l := []int{1, 2, 3}
+	q, r, e, b := divide(7, 2), remainder(7, 2), index(l, 2), letter("abc", 1)
+	fmt.Println(q, r, e, b)
+	fmt.Println(slice(l, 1, 3), l[:2], l[1:])
+	divide(1, 0)
+	remainder(1, 0)
+	index(l, 5)
+	index(l, -1)
+	store(l, 3, 0)
+	slice(l, 0, 5)
+	slice(l, 2, 1)
+	letter("abc", 3)
+	literal(3)
+	fmt.Println(literal(2))
+	deref(nil)
+	str(42)
+	x := 5
+	y := deref(&x)
+	ok := str("ok")
+	fmt.Println(y, ok)
+	var a interface{} = 3.5
+	f, isFloat := a.(float64)
+	fmt.Println(f, isFloat)
+	s, isString := a.(string)
+	fmt.Println(s == "", isString)
+	sub := l[1:2]
+	sub = append(sub, 42)
+	fmt.Println(l, sub, len(sub), cap(sub))
+	sub = append(sub, 43)
+	sub[0] = 0
+	fmt.Println(l, sub)
+}
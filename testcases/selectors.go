@@ -0,0 +1,90 @@
+package main
+
+import "fmt"
+
+type Engine struct {
+	Power int
+}
+
+type Car struct {
+	Name  string
+	Motor *Engine
+	Spare Engine
+}
+
+type Garage struct {
+	Cars  [2]*Car
+	Owner *Car
+}
+
+// Fields and methods are promoted from embedded fields, also through pointers
+type Truck struct {
+	Engine
+	*Car
+	Load int
+}
+
+type Fleet struct {
+	*Truck
+	Size
+}
+
+type Size int
+
+func (e Engine) Describe() string {
+	return fmt.Sprintf("%d hp", e.Power)
+}
+
+func (e *Engine) Tune(extra int) {
+	e.Power += extra
+}
+
+func (c *Car) Rename(name string) {
+	c.Name = name
+}
+
+func main() {
+	c := &Car{Name: "old", Motor: &Engine{100}}
+	c.Name = "car"
+	c.Motor.Power += 50
+	c.Spare.Power = 10
+	fmt.Println(c.Name, c.Motor.Power, c.Spare.Power)
+
+	// Method calls on pointers, and on fields that are pointers
+	c.Motor.Tune(25)
+	c.Spare.Tune(5)
+	fmt.Println(c.Motor.Describe(), c.Spare.Describe())
+	c.Rename("renamed")
+	fmt.Println(c.Name)
+
+	// Several levels of pointers
+	g := &Garage{Owner: c}
+	g.Cars[0] = c
+	g.Cars[1] = &Car{Name: "other", Motor: &Engine{80}}
+	g.Owner.Motor.Power = 300
+	fmt.Println(g.Cars[0].Motor.Power, g.Cars[1].Name, g.Cars[1].Motor.Describe())
+
+	// Pointers to arrays
+	arr := [3]int{1, 2, 3}
+	pa := &arr
+	pa[1] = 20
+	fmt.Println(pa[0], pa[1], pa[2], pa[1:])
+
+	// Embedded fields
+	t := &Truck{Engine: Engine{200}, Car: &Car{Name: "truck", Motor: &Engine{1}}}
+	t.Power += 20
+	t.Tune(5)
+	t.Rename("hauler")
+	fmt.Println(t.Power, t.Engine.Power, t.Describe(), t.Name, t.Motor.Power, t.Load)
+	f := Fleet{t, 3}
+	f.Load = 7
+	f.Tune(1)
+	fmt.Println(f.Power, f.Name, f.Load, f.Size, f.Truck.Engine)
+
+	// Nil pointers panic
+	defer func() {
+		fmt.Println("recovered:", recover())
+	}()
+	var empty *Car
+	fmt.Println(empty.Name)
+}
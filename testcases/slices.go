@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	s := []int{1, 2, 3}
+	s = append(s, 4, 5)
+	fmt.Println(s, len(s))
+
+	var empty []string
+	fmt.Println(empty == nil, len(empty))
+	empty = append(empty, "a")
+	fmt.Println(empty, empty == nil)
+
+	m := make([]int, 2, 10)
+	fmt.Println(m, len(m), cap(m))
+
+	// Slices share the backing array
+	t := s[1:3]
+	t[0] = 20
+	fmt.Println(s, t, len(t), cap(t))
+	u := s[:2]
+	v := s[3:]
+	fmt.Println(u, v)
+	w := s[1:2:3]
+	fmt.Println(w, cap(w))
+
+	var word string = "hello"
+	fmt.Println(word[1:4])
+
+	for i, x := range s[2:] {
+		fmt.Println(i, x)
+	}
+}
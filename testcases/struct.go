@@ -15,4 +15,7 @@ type V3 Vec3
 func main() {
 	v := &V3{1.2, 3.4, 5.6}
 	fmt.Println(v)
+	v.X = 1
+	v.Z += v.Y
+	fmt.Println(v.X, v.Y, v.Z)
 }
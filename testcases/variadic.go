@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+)
+
+func sum(xs ...int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func count(name string, values ...int) int {
+	fmt.Println(name, "has", len(values), "values")
+	return len(values)
+}
+
+func isNil(xs ...int) bool {
+	return xs == nil
+}
+
+func doubleAll(xs ...int) {
+	for i := range xs {
+		xs[i] *= 2
+	}
+}
+
+func logf(format string, args ...interface{}) {
+	fmt.Printf("log: "+format+"\n", args...)
+}
+
+func show(args ...any) {
+	fmt.Println(args...)
+	fmt.Print(args...)
+	fmt.Println()
+	s := fmt.Sprint(args...)
+	fmt.Println(len(s))
+}
+
+func main() {
+	fmt.Println(sum(), sum(1), sum(1, 2, 3))
+
+	nums := []int{4, 5, 6}
+	fmt.Println(sum(nums...))
+	fmt.Println(count("nums", nums...) + count("none"))
+	fmt.Println(isNil(), isNil(nums...), isNil([]int{}...))
+
+	// The slice is passed as it is, so the function can modify its elements
+	doubleAll(nums...)
+	fmt.Println(nums)
+
+	f := sum
+	fmt.Println(f(10, 20))
+
+	more := append(nums, nums...)
+	more = append(more, []int{7, 8}...)
+	fmt.Println(more, len(more))
+
+	bytes := append([]byte{103, 111}, "pher"...)
+	fmt.Println(len(bytes))
+
+	logf("%d + %d = %d", 2, 3, 5)
+	logf("%s is %5.2f%% done", "copying", 42.5)
+	logf("[%5d] [%-5d] [%x] [%c] [%q] [%t]", 42, 42, 255, 'G', "quoted", true)
+	logf("%d", "text")
+	logf("%d %d", 1)
+	logf("none", 1)
+	show("a", 1, 2, "b", "c", true)
+}
@@ -0,0 +1,77 @@
+package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Shape struct {
+	Name   string
+	Center Point
+	Points [2]Point
+	Next   *Shape
+	Tags   []string
+	Scale  float64
+	Hidden bool
+}
+
+type Box struct {
+	Value interface{}
+	Empty struct{}
+	Label string
+}
+
+func zero() (n int, s string, p Point, ok bool) {
+	return
+}
+
+func main() {
+	var i int
+	var u8 uint8
+	var f float64
+	var s string
+	var b bool
+	var r rune
+	fmt.Println(i, u8, f, s == "", b, r)
+
+	var p Point
+	fmt.Println(p.X, p.Y)
+
+	var shape Shape
+	fmt.Println(shape.Name == "", shape.Center.X, shape.Points[1].Y, shape.Next == nil, shape.Tags == nil, len(shape.Tags), shape.Scale, shape.Hidden)
+
+	var ptr *Point
+	var m map[string]int
+	var sl []int
+	var fn func(int) int
+	var a any
+	fmt.Println(ptr == nil, m == nil, len(m), m["missing"], sl == nil, len(sl), fn == nil, a == nil)
+
+	var arr [3]int
+	var grid [2][2]float64
+	fmt.Println(arr, grid[1][1])
+
+	var x, y int
+	fmt.Println(x, y)
+
+	partial := Shape{Name: "partial"}
+	fmt.Println(partial.Name, partial.Center.Y, partial.Scale, partial.Next == nil)
+	q := Point{Y: 2}
+	fmt.Println(q.X, q.Y)
+
+	points := make([]Point, 2)
+	fmt.Println(points[1].X, points[0].Y)
+
+	np := new(Point)
+	fmt.Println((*np).X, (*np).Y)
+
+	var box Box
+	fmt.Println(box.Value == nil, box.Label == "", box.Empty)
+	box.Value = 42
+	box.Label = "box"
+	fmt.Println(box)
+
+	n, str, zp, ok := zero()
+	fmt.Println(n, str == "", zp.X, zp.Y, ok)
+}
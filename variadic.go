@@ -0,0 +1,127 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// fmtSpreadFunctions are the functions in fmt that can be given a slice of
+// values with "args...", and the functions and print functions that replace them
+var fmtSpreadFunctions = map[string]struct{ function, print string }{
+	"Sprint":   {"_go_sprint", ""},
+	"Sprintln": {"_go_sprintln", ""},
+	"Sprintf":  {"_go_sprintf", ""},
+	"Print":    {"_go_sprint", "Print"},
+	"Println":  {"_go_sprintln", "Print"},
+	"Printf":   {"_go_sprintf", "Print"},
+}
+
+// Variadic rewrites the variadic functions to functions that take a slice,
+// and the calls of them to calls that give a slice:
+//
+//	func sum(xs ...int) int  ->  func sum(xs []int) int
+//	sum(1, 2, 3)             ->  sum([]int{1, 2, 3})
+//	sum()                    ->  sum(nil)
+//	sum(nums...)             ->  sum(nums)
+//	append(a, b...)          ->  _go_append_slice(a, b)
+//	fmt.Println(args...)     ->  fmt.Print(_go_sprintln(args))
+//
+// A slice that is given with "..." is passed as it is, sharing the backing array, like in Go.
+func Variadic(source string) string {
+	fset, file := parseSource(source)
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	pkg := checkSource(fset, file, info)
+
+	changed := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if variadicCall(n, info, pkg) {
+				changed = true
+			}
+		case *ast.FuncType:
+			if params := n.Params; params != nil && len(params.List) > 0 {
+				last := params.List[len(params.List)-1]
+				if ellipsis, ok := last.Type.(*ast.Ellipsis); ok {
+					last.Type = &ast.ArrayType{Lbrack: ellipsis.Pos(), Elt: ellipsis.Elt}
+					changed = true
+				}
+			}
+		}
+		return true
+	})
+	if !changed {
+		return source
+	}
+
+	return formatFile(fset, file)
+}
+
+// variadicCall rewrites a call of a variadic function, so that the variadic
+// arguments are given as a slice. Returns true if the call was rewritten.
+func variadicCall(call *ast.CallExpr, info *types.Info, pkg *types.Package) bool {
+	// Functions from other packages, and builtin functions
+	var obj types.Object
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		obj = info.Uses[fun]
+	case *ast.SelectorExpr:
+		obj = info.Uses[fun.Sel]
+	}
+	if b, ok := obj.(*types.Builtin); ok {
+		if b.Name() == "append" && call.Ellipsis.IsValid() && len(call.Args) == 2 {
+			call.Fun = &ast.Ident{NamePos: call.Fun.Pos(), Name: "_go_append_slice"}
+			call.Ellipsis = token.NoPos
+			return true
+		}
+		return false
+	}
+	if obj != nil && obj.Pkg() != nil && obj.Pkg() != pkg {
+		spread, ok := fmtSpreadFunctions[obj.Name()]
+		if !ok || obj.Pkg().Path() != "fmt" || !call.Ellipsis.IsValid() {
+			return false
+		}
+		// fmt.Println(args...) -> fmt.Print(_go_sprintln(args))
+		call.Ellipsis = token.NoPos
+		if spread.print == "" {
+			call.Fun = &ast.Ident{NamePos: call.Fun.Pos(), Name: spread.function}
+			return true
+		}
+		inner := &ast.CallExpr{Fun: &ast.Ident{NamePos: call.Lparen, Name: spread.function}, Lparen: call.Lparen, Args: call.Args, Rparen: call.Rparen}
+		call.Fun.(*ast.SelectorExpr).Sel.Name = spread.print
+		call.Args = []ast.Expr{inner}
+		return true
+	}
+
+	sig, ok := info.Types[call.Fun].Type.(*types.Signature)
+	if !ok || !sig.Variadic() {
+		return false
+	}
+	if call.Ellipsis.IsValid() {
+		// f(s...) passes the slice as it is
+		call.Ellipsis = token.NoPos
+		return true
+	}
+	fixed := sig.Params().Len() - 1
+	if len(call.Args) == fixed {
+		// No variadic arguments gives a nil slice
+		call.Args = append(call.Args, &ast.Ident{NamePos: call.Rparen, Name: "nil"})
+		return true
+	}
+	sliceType := goTypeString(sig.Params().At(fixed).Type())
+	if t, ok := sig.Params().At(fixed).Type().(*types.Slice); ok && types.IsInterface(t.Elem()) && t.Elem().Underlying().(*types.Interface).Empty() {
+		sliceType = "[]any"
+	}
+	literal := &ast.CompositeLit{
+		Type:   parseGenerated(sliceType, call.Args[fixed].Pos()),
+		Lbrace: call.Args[fixed].Pos(),
+		Elts:   call.Args[fixed:],
+		Rbrace: call.Rparen,
+	}
+	call.Args = append(call.Args[:fixed:fixed], literal)
+	return true
+}